@@ -1,12 +1,28 @@
 package main
 
 import (
+	"context"
+	"embed"
+	"errors"
 	"fmt"
 	"github.com/demo-go/msgo"
+	"github.com/demo-go/msgo/breaker"
+	"github.com/demo-go/msgo/metrics"
+	"github.com/demo-go/msgo/ratelimit"
+	"github.com/demo-go/msgo/sessions"
+	"github.com/demo-go/msgo/tracing"
+	"github.com/demo-go/msgo/tus"
+	"github.com/demo-go/msgo/websocket"
+	"io"
 	"log"
 	"net/http"
+	"os"
+	"time"
 )
 
+//go:embed tpl views
+var assets embed.FS
+
 type User struct {
 	Name    string   `json:"name"`
 	Age     int      `json:"age"`
@@ -22,8 +38,41 @@ func Log(next msgo.HandleFunc) msgo.HandleFunc {
 	}
 }
 
+// Auth 把当前用户保存到 Context 中 handler 通过 ctx.GetString("user") 读取
+func Auth(next msgo.HandleFunc) msgo.HandleFunc {
+	return func(ctx *msgo.Context) {
+		user := ctx.R.Header.Get("X-User")
+		if user == "" {
+			// 浏览器通过 /user/remember 设置的签名 cookie 登录
+			user, _ = ctx.SignedCookie("user")
+		}
+		if session := ctx.Session(); user == "" && session != nil {
+			// 通过 /user/login 登录的用户
+			user = session.GetString("user")
+		}
+		if user == "" {
+			ctx.W.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		ctx.Set("user", user)
+		ctx.Set("loginAt", time.Now())
+		next(ctx)
+	}
+}
+
 func main() {
 	engine := msgo.New()
+	engine.Tracer = tracing.NewTracer(tracing.Config{
+		ServiceName: "blog",
+		Exporter:    &tracing.StdoutExporter{},
+	})
+	cookieKey := os.Getenv("BLOG_COOKIE_KEY")
+	if cookieKey == "" {
+		cookieKey = "blog-development-cookie-key-change-me"
+	}
+	if err := engine.SetCookieKeys([]byte(cookieKey)); err != nil {
+		log.Fatal(err)
+	}
 	g := engine.Group("user")
 	g.Use(func(next msgo.HandleFunc) msgo.HandleFunc {
 		return func(ctx *msgo.Context) {
@@ -32,6 +81,26 @@ func main() {
 			fmt.Println("use post middleware")
 		}
 	})
+	g.Use(msgo.RequestID(msgo.RequestIDConfig{}))
+	g.Use(msgo.Compress(msgo.CompressConfig{}))
+	g.Use(msgo.CORS(msgo.CORSConfig{
+		AllowOrigins:     []string{"http://localhost:*", "https://*.dema-go.com"},
+		AllowCredentials: true,
+		MaxAge:           12 * time.Hour,
+	}))
+	g.Use(msgo.Metrics(msgo.MetricsConfig{
+		ExcludedPaths: []string{"/user/metrics"},
+	}))
+	g.Get("/metrics", msgo.MetricsHandler(nil))
+	sessionStore, err := sessions.NewFileStore("./sessions")
+	if err != nil {
+		log.Fatal(err)
+	}
+	g.Use(msgo.Sessions(msgo.SessionConfig{
+		Store:           sessionStore,
+		IdleTimeout:     time.Hour,
+		AbsoluteTimeout: 7 * 24 * time.Hour,
+	}))
 	g.Get("/hello", func(ctx *msgo.Context) {
 		fmt.Println("handle")
 		fmt.Fprintf(ctx.W, "%s get 欢迎来到码神之路goweb教程", "dema-go.com")
@@ -78,7 +147,9 @@ func main() {
 			log.Println(err)
 		}
 	})
-	engine.LoadTemplate("./tpl/*")
+	engine.AddTemplate("login.html", "./tpl/login.html", "./tpl/header.html")
+	engine.AddTemplate("index.html", "./tpl/index.html")
+	engine.LoadLayoutTemplate("./views/layouts/base.html", "./views/pages/*.html", "./views/partials/*.html")
 	g.Get("/template", func(ctx *msgo.Context) {
 		user := &User{
 			Name: "dema",
@@ -88,6 +159,18 @@ func main() {
 			log.Println(err)
 		}
 	})
+	g.Get("/home", func(ctx *msgo.Context) {
+		err := ctx.Template("home.html", &User{Name: "dema"})
+		if err != nil {
+			log.Println(err)
+		}
+	})
+	g.Get("/about", func(ctx *msgo.Context) {
+		err := ctx.Template("about.html", nil)
+		if err != nil {
+			log.Println(err)
+		}
+	})
 	g.Get("/json", func(ctx *msgo.Context) {
 		user := &User{
 			Name: "dema",
@@ -97,7 +180,7 @@ func main() {
 		if err != nil {
 			log.Println(err)
 		}
-	})
+	}, msgo.ETag())
 	g.Get("/xml", func(ctx *msgo.Context) {
 		user := &User{
 			Name: "dema",
@@ -116,41 +199,108 @@ func main() {
 	g.Get("/fs", func(ctx *msgo.Context) {
 		ctx.FileFromFS("test.xlsx", http.Dir("./tpl"))
 	})
+	g.Static("/static", "./tpl", msgo.CacheControl(msgo.CachePolicy{Public: true, MaxAge: time.Hour}))
+	g.StaticFS("/assets", http.FS(assets))
+	g.StaticFile("/download", "./tpl/test.xlsx")
+	g.Get("/embedFile", func(ctx *msgo.Context) {
+		ctx.FileFS("tpl/test.xlsx", assets)
+	})
+	g.Get("/embedTemplate", func(ctx *msgo.Context) {
+		user := &User{
+			Name: "dema",
+		}
+		err := ctx.HTMLTemplateFS("login.html", user, assets, "tpl/login.html", "tpl/header.html")
+		if err != nil {
+			log.Println(err)
+		}
+	})
 	g.Get("/redirect", func(ctx *msgo.Context) {
 		ctx.Redirect(http.StatusFound, "/user/template")
 	})
 	g.Get("/string", func(ctx *msgo.Context) {
-		err := ctx.String(http.StatusFound, "%s 和 %s 666", "dema", "xiya")
+		err := ctx.String(http.StatusOK, "%s 和 %s 666", "dema", "xiya")
 		if err != nil {
 			log.Println(err)
 		}
 	})
 	g.Get("/add", func(ctx *msgo.Context) {
 		name := ctx.GetDefaultQuery("name", "dema")
-		fmt.Printf("name: %s\n", name)
+		ctx.Logger().Printf("name: %s\n", name)
+	})
+	g.Get("/list", func(ctx *msgo.Context) {
+		page, err := ctx.QueryInt("page", 1)
+		if err != nil {
+			ctx.String(http.StatusBadRequest, "%v", err)
+			return
+		}
+		ids, err := ctx.QueryIntSlice("ids")
+		if err != nil {
+			ctx.String(http.StatusBadRequest, "%v", err)
+			return
+		}
+		ctx.JSON(http.StatusOK, map[string]any{"page": page, "ids": ids})
 	})
 	g.Get("/queryMap", func(ctx *msgo.Context) {
 		m, _ := ctx.GetQueryMap("user")
 		ctx.JSON(http.StatusOK, m)
 	})
+	// /user/queryNested?user[address][city]=北京&user[tags][]=go&user[tags][]=web
+	g.Get("/queryNested", func(ctx *msgo.Context) {
+		m, _ := ctx.GetQueryNestedMap("user")
+		ctx.JSON(http.StatusOK, m)
+	})
 	g.Post("/formPost", func(ctx *msgo.Context) {
 		name, _ := ctx.GetPostFormMap("user")
-		//file := ctx.FormFile("file")
-		//err := ctx.SaveUploadFile(file, "./upload/"+file.Filename)
-		//if err != nil {
-		//	log.Println(err)
-		//}
-		form, err := ctx.MultipartForm()
-		if err != nil {
-			log.Println(err)
+		//file, err := ctx.FormFile("file")
+		//path, err := ctx.SaveUploadFileTo(file, "./upload")
+		files, err := ctx.FormFiles("file")
+		if errors.Is(err, msgo.ErrBodyTooLarge) || errors.Is(err, msgo.ErrFileTooLarge) {
+			ctx.String(http.StatusRequestEntityTooLarge, "%v", err)
+			return
+		}
+		if err != nil && !errors.Is(err, http.ErrMissingFile) {
+			ctx.String(http.StatusBadRequest, "%v", err)
+			return
 		}
-		fileMap := form.File
-		headers := fileMap["file"]
-		for _, file := range headers {
-			ctx.SaveUploadFile(file, "./upload/"+file.Filename)
+		for _, file := range files {
+			if _, err := ctx.SaveUploadFileTo(file, "./upload"); err != nil {
+				log.Println(err)
+			}
 		}
 		ctx.JSON(http.StatusOK, name)
-	})
+	}, msgo.Upload(msgo.UploadConfig{
+		MaxBodySize:  10 << 20,
+		MaxFileSize:  5 << 20,
+		AllowedTypes: []string{"image/*", "application/pdf"},
+	}))
+	// 大文件边接收边写入磁盘 不缓存到内存
+	g.Post("/video", func(ctx *msgo.Context) {
+		var paths []string
+		err := ctx.StreamMultipart(msgo.MultipartConfig{
+			AllowedTypes: []string{"video/*"},
+			Progress: func(part *msgo.MultipartPart, read int64) {
+				if read%(64<<20) < 32<<10 {
+					log.Printf("upload %s: %d bytes", part.FileName(), read)
+				}
+			},
+		}, func(part *msgo.MultipartPart) error {
+			if !part.IsFile() {
+				return nil
+			}
+			path, err := ctx.SavePartTo(part, "./upload")
+			paths = append(paths, path)
+			return err
+		})
+		if errors.Is(err, msgo.ErrBodyTooLarge) || errors.Is(err, msgo.ErrFileTooLarge) {
+			ctx.String(http.StatusRequestEntityTooLarge, "%v", err)
+			return
+		}
+		if err != nil {
+			ctx.String(http.StatusBadRequest, "%v", err)
+			return
+		}
+		ctx.JSON(http.StatusOK, paths)
+	}, msgo.Upload(msgo.UploadConfig{MaxBodySize: 4 << 30, MaxFileSize: 2 << 30}))
 	g.Post("/jsonParam", func(ctx *msgo.Context) {
 		user := &User{}
 		// 开启校验 参数中有 结构体中没有 报错
@@ -163,6 +313,149 @@ func main() {
 		} else {
 			log.Println(err)
 		}
+	}, msgo.RateLimit(msgo.RateLimitConfig{
+		Limiter: ratelimit.NewTokenBucket(ratelimit.NewMemoryStore(ratelimit.MemoryStoreConfig{}), 10, time.Second, 20),
+	}))
+	breakerState := metrics.DefaultRegistry.NewGaugeVec("blog_breaker_state",
+		"Circuit breaker state: 0 closed, 1 half-open, 2 open.", "name")
+	downstream := breaker.New(breaker.Config{
+		Name:        "downstream",
+		ReadyToTrip: breaker.FailureRatio(0.5, 10),
+		Timeout:     30 * time.Second,
+		OnStateChange: func(name string, from, to breaker.State) {
+			breakerState.WithLabelValues(name).Set(float64(to))
+			log.Printf("breaker %s: %s -> %s", name, from, to)
+		},
+	})
+	g.Get("/downstream", func(ctx *msgo.Context) {
+		var body []byte
+		err := downstream.ExecuteWithFallback(func() error {
+			req, err := ctx.NewRequest(http.MethodGet, "http://localhost:8112/ping", nil)
+			if err != nil {
+				return err
+			}
+			resp, err := ctx.HTTPClient().Do(req)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode >= http.StatusInternalServerError {
+				return fmt.Errorf("downstream status %d", resp.StatusCode)
+			}
+			body, err = io.ReadAll(resp.Body)
+			return err
+		}, func(err error) error {
+			body = []byte("fallback: " + err.Error())
+			return nil
+		})
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		ctx.String(http.StatusOK, "%s", body)
+	}, msgo.Timeout(msgo.TimeoutConfig{Timeout: 3 * time.Second, StatusCode: http.StatusGatewayTimeout}))
+	g.Get("/me", func(ctx *msgo.Context) {
+		ctx.JSON(http.StatusOK, map[string]any{
+			"user":    ctx.GetString("user"),
+			"loginAt": ctx.GetTime("loginAt"),
+		})
+	}, Auth)
+	g.Post("/login", func(ctx *msgo.Context) {
+		name, ok := ctx.GetPostForm("name")
+		if !ok || name == "" {
+			ctx.String(http.StatusBadRequest, "name is required")
+			return
+		}
+		session := ctx.Session()
+		// 登录后更换会话 id 防止会话固定攻击
+		session.Regenerate()
+		session.Set("user", name)
+		session.Set("loginAt", time.Now())
+		session.Flash("notice", "欢迎回来 "+name)
+		ctx.Redirect(http.StatusSeeOther, "/user/me")
+	})
+	g.Get("/logout", func(ctx *msgo.Context) {
+		ctx.Session().Destroy()
+		ctx.String(http.StatusOK, "bye")
+	})
+	g.Get("/notice", func(ctx *msgo.Context) {
+		ctx.JSON(http.StatusOK, ctx.Session().Flashes("notice"))
+	})
+	g.Get("/remember", func(ctx *msgo.Context) {
+		name := ctx.GetDefaultQuery("name", "dema")
+		if err := ctx.SetSignedCookie("user", name, msgo.CookieOptions{MaxAge: 7 * 24 * 3600, HttpOnly: true}); err != nil {
+			ctx.String(http.StatusInternalServerError, "%v", err)
+			return
+		}
+		theme, err := ctx.Cookie("theme")
+		if err != nil {
+			theme = "light"
+			ctx.SetCookie("theme", theme)
+		}
+		ctx.JSON(http.StatusOK, map[string]any{"user": name, "theme": theme})
+	})
+	g.Get("/forget", func(ctx *msgo.Context) {
+		ctx.DeleteCookie("user")
+		ctx.String(http.StatusOK, "bye")
+	})
+	g.Get("/async", func(ctx *msgo.Context) {
+		// ctx 在 handler 返回后会被复用 goroutine 中只能使用副本
+		cp := ctx.Copy()
+		go func() {
+			time.Sleep(time.Second)
+			cp.Logger().Printf("async job done for %s %s", cp.R.Method, cp.FullPath())
+		}()
+		ctx.String(http.StatusAccepted, "accepted")
+	})
+	g.Get("/comments", func(ctx *msgo.Context) {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		count := 0
+		ctx.Stream(func(w io.Writer) bool {
+			<-ticker.C
+			count++
+			err := ctx.SSEvent("comment", map[string]any{"id": count, "content": "新评论"})
+			if err != nil {
+				log.Println(err)
+				return false
+			}
+			return count < 10
+		})
+	})
+	// 断点续传 客户端使用 tus 协议上传到 /user/attachments
+	attachments, err := tus.NewLocalStore("./upload/attachments")
+	if err != nil {
+		log.Fatal(err)
+	}
+	g.Resumable("/attachments", tus.New(tus.Config{
+		Storage: attachments,
+		MaxSize: 1 << 30,
+		OnComplete: func(r *http.Request, info tus.Info) {
+			log.Printf("attachment %s (%s) uploaded: %d bytes", info.ID, info.Metadata["filename"], info.Size)
+		},
+	}), Auth)
+	go func() {
+		for range time.Tick(time.Hour) {
+			if _, err := attachments.RemoveExpired(context.Background(), time.Now()); err != nil {
+				log.Println(err)
+			}
+			if _, err := sessionStore.RemoveExpired(time.Now()); err != nil {
+				log.Println(err)
+			}
+		}
+	}()
+	g.WebSocket("/ws", func(ctx *msgo.Context, conn *websocket.Conn) {
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				log.Println(err)
+				return
+			}
+			if err := conn.WriteMessage(messageType, data); err != nil {
+				log.Println(err)
+				return
+			}
+		}
 	})
 	engine.Run()
 }
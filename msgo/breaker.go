@@ -0,0 +1,58 @@
+package msgo
+
+import (
+	"fmt"
+	"github.com/demo-go/msgo/breaker"
+	"net/http"
+)
+
+type CircuitBreakerConfig struct {
+	// Breaker 必须设置 多个路由使用同一个 Breaker 时共享状态
+	Breaker *breaker.Breaker
+	// IsFailure 根据响应状态码判断请求是否失败 默认 5xx 算失败
+	IsFailure func(ctx *Context, status int) bool
+	// Fallback 熔断器拒绝请求时调用 默认返回 503 和 Retry-After
+	Fallback func(ctx *Context, err error)
+}
+
+// CircuitBreaker 熔断中间件 handler 连续失败时直接调用 Fallback 不再等待出问题的下游
+func CircuitBreaker(config CircuitBreakerConfig) MiddlewareFunc {
+	if config.Breaker == nil {
+		panic("msgo: CircuitBreakerConfig.Breaker is required")
+	}
+	if config.IsFailure == nil {
+		config.IsFailure = func(ctx *Context, status int) bool {
+			return status >= http.StatusInternalServerError
+		}
+	}
+	if config.Fallback == nil {
+		config.Fallback = func(ctx *Context, err error) {
+			if retryAfter := config.Breaker.RetryAfter(); retryAfter > 0 {
+				ctx.W.Header().Set("Retry-After", ceilSeconds(retryAfter))
+			}
+			ctx.W.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintf(ctx.W, "%s service unavailable \n", ctx.R.RequestURI)
+		}
+	}
+	return func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			done, err := config.Breaker.Allow()
+			if err != nil {
+				config.Fallback(ctx, err)
+				return
+			}
+			w := newResponseWriter(ctx.W)
+			ctx.W = w
+			defer func() {
+				ctx.W = w.ResponseWriter
+				// handler panic 时记为失败
+				if e := recover(); e != nil {
+					done(false)
+					panic(e)
+				}
+			}()
+			next(ctx)
+			done(!config.IsFailure(ctx, w.Status()))
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package breaker
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type State int
+
+const (
+	StateClosed State = iota
+	StateHalfOpen
+	StateOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half-open"
+	case StateOpen:
+		return "open"
+	default:
+		return fmt.Sprintf("unknown state: %d", int(s))
+	}
+}
+
+var (
+	// ErrOpen 熔断器打开时直接拒绝请求
+	ErrOpen = errors.New("breaker: circuit breaker is open")
+	// ErrTooManyRequests 半开状态下试探的请求数已经达到 MaxRequests
+	ErrTooManyRequests = errors.New("breaker: too many requests")
+)
+
+// Counts 关闭状态下滚动窗口内的统计 连续成功和连续失败不受窗口限制
+type Counts struct {
+	Requests             uint32
+	Successes            uint32
+	Failures             uint32
+	ConsecutiveSuccesses uint32
+	ConsecutiveFailures  uint32
+}
+
+// TripFunc 每次失败后调用 返回 true 时熔断器打开
+type TripFunc func(counts Counts) bool
+
+// ConsecutiveFailures 连续失败 n 次后打开
+func ConsecutiveFailures(n uint32) TripFunc {
+	return func(counts Counts) bool {
+		return counts.ConsecutiveFailures >= n
+	}
+}
+
+// FailureRatio 窗口内至少有 minRequests 个请求 并且失败率达到 ratio 后打开
+func FailureRatio(ratio float64, minRequests uint32) TripFunc {
+	return func(counts Counts) bool {
+		return counts.Requests >= minRequests && float64(counts.Failures)/float64(counts.Requests) >= ratio
+	}
+}
+
+type Config struct {
+	Name string
+	// MaxRequests 半开状态下允许通过的试探请求数 全部成功后关闭 默认 1
+	MaxRequests uint32
+	// Window 关闭状态下统计请求的滚动窗口 默认 60 秒
+	Window time.Duration
+	// Buckets 滚动窗口分成的桶数 默认 10
+	Buckets int
+	// Timeout 打开状态持续的时间 之后进入半开状态 默认 60 秒
+	Timeout time.Duration
+	// ReadyToTrip 默认 ConsecutiveFailures(5)
+	ReadyToTrip TripFunc
+	// IsSuccessful 判断 Execute 返回的错误是否算成功 默认只有 nil 算成功
+	IsSuccessful func(err error) bool
+	// OnStateChange 状态变化时调用 可以用来记录指标或日志 不能在里面调用熔断器的方法
+	OnStateChange func(name string, from, to State)
+}
+
+type bucket struct {
+	start     time.Time
+	successes uint32
+	failures  uint32
+}
+
+type Breaker struct {
+	name          string
+	maxRequests   uint32
+	bucketSize    time.Duration
+	timeout       time.Duration
+	readyToTrip   TripFunc
+	isSuccessful  func(err error) bool
+	onStateChange func(name string, from, to State)
+
+	mu       sync.Mutex
+	state    State
+	buckets  []bucket
+	counts   Counts
+	halfOpen uint32
+	// generation 每次状态变化加一 旧状态下开始的请求结束时不再计数
+	generation uint64
+	openUntil  time.Time
+}
+
+func New(config Config) *Breaker {
+	if config.MaxRequests == 0 {
+		config.MaxRequests = 1
+	}
+	if config.Window <= 0 {
+		config.Window = 60 * time.Second
+	}
+	if config.Buckets <= 0 {
+		config.Buckets = 10
+	}
+	if config.Window < time.Duration(config.Buckets) {
+		config.Buckets = 1
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 60 * time.Second
+	}
+	if config.ReadyToTrip == nil {
+		config.ReadyToTrip = ConsecutiveFailures(5)
+	}
+	if config.IsSuccessful == nil {
+		config.IsSuccessful = func(err error) bool {
+			return err == nil
+		}
+	}
+	return &Breaker{
+		name:          config.Name,
+		maxRequests:   config.MaxRequests,
+		bucketSize:    config.Window / time.Duration(config.Buckets),
+		timeout:       config.Timeout,
+		readyToTrip:   config.ReadyToTrip,
+		isSuccessful:  config.IsSuccessful,
+		onStateChange: config.OnStateChange,
+		buckets:       make([]bucket, config.Buckets),
+	}
+}
+
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.currentState(time.Now())
+}
+
+func (b *Breaker) Counts() Counts {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.currentState(time.Now())
+	return b.windowCounts(time.Now())
+}
+
+// RetryAfter 打开状态下距离进入半开状态的时间 其他状态返回 0
+func (b *Breaker) RetryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	if b.currentState(now) != StateOpen {
+		return 0
+	}
+	return b.openUntil.Sub(now)
+}
+
+// Allow 判断请求能否通过 通过时返回 done 请求结束后必须调用一次 done 报告结果
+func (b *Breaker) Allow() (done func(success bool), err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	switch b.currentState(now) {
+	case StateOpen:
+		return nil, ErrOpen
+	case StateHalfOpen:
+		if b.halfOpen >= b.maxRequests {
+			return nil, ErrTooManyRequests
+		}
+		b.halfOpen++
+	}
+	generation := b.generation
+	var once sync.Once
+	return func(success bool) {
+		once.Do(func() {
+			b.done(generation, success)
+		})
+	}, nil
+}
+
+// Execute 熔断器允许时执行 fn 并根据返回的错误计数 否则返回 ErrOpen 或 ErrTooManyRequests
+// fn panic 时记为失败并继续 panic
+func (b *Breaker) Execute(fn func() error) (err error) {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if e := recover(); e != nil {
+			done(false)
+			panic(e)
+		}
+	}()
+	err = fn()
+	done(b.isSuccessful(err))
+	return err
+}
+
+// ExecuteWithFallback fn 被拒绝或者失败时调用 fallback 参数为拒绝或失败的原因
+func (b *Breaker) ExecuteWithFallback(fn func() error, fallback func(err error) error) error {
+	err := b.Execute(fn)
+	if err != nil && fallback != nil {
+		return fallback(err)
+	}
+	return err
+}
+
+func (b *Breaker) done(generation uint64, success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	state := b.currentState(now)
+	if generation != b.generation {
+		return
+	}
+	if success {
+		b.onSuccess(state, now)
+	} else {
+		b.onFailure(state, now)
+	}
+}
+
+func (b *Breaker) onSuccess(state State, now time.Time) {
+	b.counts.ConsecutiveSuccesses++
+	b.counts.ConsecutiveFailures = 0
+	switch state {
+	case StateClosed:
+		b.bucket(now).successes++
+	case StateHalfOpen:
+		if b.counts.ConsecutiveSuccesses >= b.maxRequests {
+			b.setState(StateClosed, now)
+		}
+	}
+}
+
+func (b *Breaker) onFailure(state State, now time.Time) {
+	b.counts.ConsecutiveFailures++
+	b.counts.ConsecutiveSuccesses = 0
+	switch state {
+	case StateClosed:
+		b.bucket(now).failures++
+		if b.readyToTrip(b.windowCounts(now)) {
+			b.setState(StateOpen, now)
+		}
+	case StateHalfOpen:
+		b.setState(StateOpen, now)
+	}
+}
+
+// currentState 打开状态超时后切换到半开状态
+func (b *Breaker) currentState(now time.Time) State {
+	if b.state == StateOpen && !now.Before(b.openUntil) {
+		b.setState(StateHalfOpen, now)
+	}
+	return b.state
+}
+
+func (b *Breaker) setState(state State, now time.Time) {
+	if b.state == state {
+		return
+	}
+	from := b.state
+	b.state = state
+	b.generation++
+	b.counts = Counts{}
+	b.halfOpen = 0
+	for i := range b.buckets {
+		b.buckets[i] = bucket{}
+	}
+	if state == StateOpen {
+		b.openUntil = now.Add(b.timeout)
+	}
+	if b.onStateChange != nil {
+		b.onStateChange(b.name, from, state)
+	}
+}
+
+// bucket 返回 now 所在的桶 桶属于更早的窗口时先清空
+func (b *Breaker) bucket(now time.Time) *bucket {
+	start := now.Truncate(b.bucketSize)
+	i := int(start.UnixNano()/int64(b.bucketSize)) % len(b.buckets)
+	if i < 0 {
+		i += len(b.buckets)
+	}
+	if !b.buckets[i].start.Equal(start) {
+		b.buckets[i] = bucket{start: start}
+	}
+	return &b.buckets[i]
+}
+
+func (b *Breaker) windowCounts(now time.Time) Counts {
+	counts := Counts{
+		ConsecutiveSuccesses: b.counts.ConsecutiveSuccesses,
+		ConsecutiveFailures:  b.counts.ConsecutiveFailures,
+	}
+	oldest := now.Truncate(b.bucketSize).Add(-b.bucketSize * time.Duration(len(b.buckets)-1))
+	for _, bk := range b.buckets {
+		if bk.start.Before(oldest) {
+			continue
+		}
+		counts.Successes += bk.successes
+		counts.Failures += bk.failures
+	}
+	counts.Requests = counts.Successes + counts.Failures
+	return counts
+}
@@ -0,0 +1,120 @@
+package breaker_test
+
+import (
+	"errors"
+	"github.com/demo-go/msgo"
+	"github.com/demo-go/msgo/breaker"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var errDownstream = errors.New("downstream failed")
+
+func fail() error {
+	return errDownstream
+}
+
+func succeed() error {
+	return nil
+}
+
+func TestStateTransitions(t *testing.T) {
+	var transitions []string
+	b := breaker.New(breaker.Config{
+		Name:        "db",
+		Timeout:     20 * time.Millisecond,
+		ReadyToTrip: breaker.ConsecutiveFailures(3),
+		OnStateChange: func(name string, from, to breaker.State) {
+			transitions = append(transitions, name+":"+from.String()+"->"+to.String())
+		},
+	})
+	for i := 0; i < 3; i++ {
+		if err := b.Execute(fail); err != errDownstream {
+			t.Fatalf("err = %v", err)
+		}
+	}
+	if b.State() != breaker.StateOpen {
+		t.Fatalf("state = %s", b.State())
+	}
+	if err := b.Execute(succeed); err != breaker.ErrOpen {
+		t.Fatalf("err = %v", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if b.State() != breaker.StateHalfOpen {
+		t.Fatalf("state = %s", b.State())
+	}
+	// 半开状态下只放行 MaxRequests 个试探请求
+	done, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Allow(); err != breaker.ErrTooManyRequests {
+		t.Fatalf("err = %v", err)
+	}
+	done(true)
+	if b.State() != breaker.StateClosed {
+		t.Fatalf("state = %s", b.State())
+	}
+
+	want := []string{"db:closed->open", "db:open->half-open", "db:half-open->closed"}
+	if len(transitions) != len(want) {
+		t.Fatalf("transitions = %v", transitions)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Fatalf("transitions = %v", transitions)
+		}
+	}
+}
+
+func TestFailureRatio(t *testing.T) {
+	b := breaker.New(breaker.Config{ReadyToTrip: breaker.FailureRatio(0.5, 4)})
+	b.Execute(succeed)
+	b.Execute(fail)
+	b.Execute(succeed)
+	if b.State() != breaker.StateClosed {
+		t.Fatal("tripped before minimum requests")
+	}
+	b.Execute(fail)
+	if b.State() != breaker.StateOpen {
+		t.Fatalf("state = %s counts = %+v", b.State(), b.Counts())
+	}
+}
+
+func TestFallback(t *testing.T) {
+	b := breaker.New(breaker.Config{})
+	err := b.ExecuteWithFallback(fail, func(err error) error {
+		if err != errDownstream {
+			t.Fatalf("fallback err = %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	b := breaker.New(breaker.Config{ReadyToTrip: breaker.ConsecutiveFailures(2), Timeout: time.Minute})
+	engine := msgo.New()
+	calls := 0
+	engine.Group("user").Get("/downstream", func(ctx *msgo.Context) {
+		calls++
+		ctx.W.WriteHeader(http.StatusBadGateway)
+	}, msgo.CircuitBreaker(msgo.CircuitBreakerConfig{Breaker: b}))
+
+	var w *httptest.ResponseRecorder
+	for i := 0; i < 3; i++ {
+		w = httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/user/downstream", nil))
+	}
+	if calls != 2 {
+		t.Fatalf("handler called %d times", calls)
+	}
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "60" {
+		t.Fatalf("fallback response: %d %v", w.Code, w.Header())
+	}
+}
@@ -0,0 +1,211 @@
+package msgo
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// etagMaxBufferSize 超过这个大小的响应不再缓冲 直接输出并且不计算 ETag
+const etagMaxBufferSize = 4 << 20
+
+type CachePolicy struct {
+	MaxAge               time.Duration
+	SMaxAge              time.Duration
+	StaleWhileRevalidate time.Duration
+	Public               bool
+	Private              bool
+	NoCache              bool
+	NoStore              bool
+	MustRevalidate       bool
+	Immutable            bool
+}
+
+var (
+	// CacheNoStore 不允许任何缓存
+	CacheNoStore = CachePolicy{NoStore: true}
+	// CacheRevalidate 允许缓存 但每次使用前都要向服务端验证
+	CacheRevalidate = CachePolicy{NoCache: true}
+	// CacheImmutable 适用于文件名带版本号的静态资源
+	CacheImmutable = CachePolicy{Public: true, MaxAge: 365 * 24 * time.Hour, Immutable: true}
+)
+
+func (p CachePolicy) String() string {
+	var directives []string
+	if p.Public {
+		directives = append(directives, "public")
+	}
+	if p.Private {
+		directives = append(directives, "private")
+	}
+	if p.NoCache {
+		directives = append(directives, "no-cache")
+	}
+	if p.NoStore {
+		directives = append(directives, "no-store")
+	}
+	if p.MaxAge > 0 {
+		directives = append(directives, "max-age="+strconv.FormatInt(int64(p.MaxAge/time.Second), 10))
+	}
+	if p.SMaxAge > 0 {
+		directives = append(directives, "s-maxage="+strconv.FormatInt(int64(p.SMaxAge/time.Second), 10))
+	}
+	if p.StaleWhileRevalidate > 0 {
+		directives = append(directives, "stale-while-revalidate="+strconv.FormatInt(int64(p.StaleWhileRevalidate/time.Second), 10))
+	}
+	if p.MustRevalidate {
+		directives = append(directives, "must-revalidate")
+	}
+	if p.Immutable {
+		directives = append(directives, "immutable")
+	}
+	return strings.Join(directives, ", ")
+}
+
+// CacheControl 为路由设置 Cache-Control 头 handler 中可以再次覆盖
+func CacheControl(policy CachePolicy) MiddlewareFunc {
+	value := policy.String()
+	return func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			if value != "" {
+				ctx.W.Header().Set("Cache-Control", value)
+			}
+			next(ctx)
+		}
+	}
+}
+
+// ETag 缓冲 GET 和 HEAD 请求的 200 响应 根据 body 计算 ETag
+// 请求的 If-None-Match 匹配时返回 304
+func ETag() MiddlewareFunc {
+	return func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			if ctx.R.Method != http.MethodGet && ctx.R.Method != http.MethodHead {
+				next(ctx)
+				return
+			}
+			w := &etagWriter{ResponseWriter: ctx.W}
+			ctx.W = w
+			defer func() {
+				ctx.W = w.ResponseWriter
+			}()
+			next(ctx)
+			w.finish(ctx.R)
+		}
+	}
+}
+
+type etagWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	passthrough bool
+	buf         bytes.Buffer
+}
+
+func (w *etagWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.status = code
+	w.wroteHeader = true
+	// 只有 200 的响应需要计算 ETag
+	if code != http.StatusOK {
+		w.startPassthrough()
+	}
+}
+
+func (w *etagWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+	if w.buf.Len()+len(b) > etagMaxBufferSize {
+		w.startPassthrough()
+		return w.ResponseWriter.Write(b)
+	}
+	return w.buf.Write(b)
+}
+
+// startPassthrough 放弃计算 ETag 把已经缓冲的内容写出去
+func (w *etagWriter) startPassthrough() {
+	if w.passthrough {
+		return
+	}
+	w.passthrough = true
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.buf.Len() > 0 {
+		_, _ = w.ResponseWriter.Write(w.buf.Bytes())
+		w.buf.Reset()
+	}
+}
+
+// Flush 流式响应不计算 ETag
+func (w *etagWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.startPassthrough()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *etagWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the ResponseWriter doesn't support the Hijacker interface")
+	}
+	w.passthrough = true
+	return hijacker.Hijack()
+}
+
+func (w *etagWriter) finish(r *http.Request) {
+	if w.passthrough || !w.wroteHeader {
+		return
+	}
+	// HEAD 请求没有 body 无法计算出和 GET 一致的 ETag
+	if r.Method == http.MethodHead && w.buf.Len() == 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+		return
+	}
+	header := w.ResponseWriter.Header()
+	etag := header.Get("ETag")
+	if etag == "" {
+		sum := sha1.Sum(w.buf.Bytes())
+		etag = `"` + hex.EncodeToString(sum[:]) + `"`
+		header.Set("ETag", etag)
+	}
+	if etagMatch(r.Header.Get("If-None-Match"), etag) {
+		header.Del("Content-Type")
+		header.Del("Content-Length")
+		w.ResponseWriter.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	_, _ = w.ResponseWriter.Write(w.buf.Bytes())
+}
+
+// etagMatch If-None-Match 使用弱比较 RFC 7232 3.2
+func etagMatch(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package msgo
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// SetTrustedProxies 设置可信的代理 支持 IP 和 CIDR
+// 只有请求来自可信代理时 ClientIP 才会读取 X-Forwarded-For 和 X-Real-IP 默认不信任任何代理
+func (e *Engine) SetTrustedProxies(proxies []string) error {
+	var nets []*net.IPNet
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return &net.ParseError{Type: "IP address", Text: proxy}
+			}
+			bits := 32
+			if ip.To4() == nil {
+				bits = 128
+			}
+			proxy += "/" + strconv.Itoa(bits)
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return err
+		}
+		nets = append(nets, ipNet)
+	}
+	e.trustedProxies = nets
+	return nil
+}
+
+func (e *Engine) isTrustedProxy(ip net.IP) bool {
+	for _, ipNet := range e.trustedProxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP 返回客户端的 IP
+// 请求来自可信代理时 从右往左找到 X-Forwarded-For 中第一个不可信的地址 其次是 X-Real-IP
+func (c *Context) ClientIP() string {
+	remote := remoteIP(c.R)
+	if remote == nil {
+		return ""
+	}
+	if c.engine == nil || !c.engine.isTrustedProxy(remote) {
+		return remote.String()
+	}
+	if forwarded := c.R.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		addrs := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(addrs) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(addrs[i]))
+			if ip == nil {
+				break
+			}
+			if i == 0 || !c.engine.isTrustedProxy(ip) {
+				return ip.String()
+			}
+		}
+	}
+	if ip := net.ParseIP(strings.TrimSpace(c.R.Header.Get("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+	return remote.String()
+}
+
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(r.RemoteAddr))
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
@@ -0,0 +1,404 @@
+package msgo
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CompressWriter gzip.Writer 和 zlib.Writer 都满足这个接口 可以放进 sync.Pool 复用
+type CompressWriter interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+type CompressWriterFunc func(w io.Writer, level int) (CompressWriter, error)
+
+var (
+	encodingsMu sync.RWMutex
+	encodings   = map[string]CompressWriterFunc{
+		"gzip": func(w io.Writer, level int) (CompressWriter, error) {
+			return gzip.NewWriterLevel(w, level)
+		},
+		"deflate": func(w io.Writer, level int) (CompressWriter, error) {
+			return zlib.NewWriterLevel(w, level)
+		},
+	}
+)
+
+// RegisterEncoding 注册新的压缩算法 例如 br 注册后需要在 CompressConfig.Encodings 中启用
+func RegisterEncoding(name string, fn CompressWriterFunc) {
+	encodingsMu.Lock()
+	defer encodingsMu.Unlock()
+	encodings[name] = fn
+}
+
+type CompressConfig struct {
+	// Level 传给压缩算法的压缩级别 0 表示各算法的默认级别 即 -1
+	// 因此不能通过 Level 选择 gzip.NoCompression 不需要压缩的路径请使用 ExcludedPaths
+	Level int
+	// MinLength 小于这个长度的响应不压缩 默认 1024
+	MinLength int
+	// Encodings 服务端支持的算法 按优先级排列 默认 br gzip deflate 中已经注册的
+	Encodings []string
+	// ExcludedContentTypes 按前缀匹配 这些类型的响应不压缩
+	ExcludedContentTypes []string
+	// ExcludedPaths 按前缀匹配 这些路径的响应不压缩
+	ExcludedPaths []string
+	// MaxDecompressedSize 解压后请求体的最大字节数 超过后读取请求体返回 ErrBodyTooLarge 默认 10MB
+	MaxDecompressedSize int64
+}
+
+// defaultMaxDecompressedSize 防止很小的压缩请求体解压后占满内存
+const defaultMaxDecompressedSize = 10 << 20
+
+var defaultExcludedContentTypes = []string{
+	"image/png", "image/jpeg", "image/gif", "image/webp",
+	"video/", "audio/",
+	"application/zip", "application/gzip", "application/x-gzip", "application/x-brotli",
+	"font/woff", "font/woff2",
+	"text/event-stream",
+}
+
+// Compress 根据 Accept-Encoding 压缩响应 同时解压 Content-Encoding 为 gzip 或 deflate 的请求体
+func Compress(config CompressConfig) MiddlewareFunc {
+	if config.Level == 0 {
+		config.Level = flate.DefaultCompression
+	}
+	if config.MinLength <= 0 {
+		config.MinLength = 1024
+	}
+	if config.Encodings == nil {
+		config.Encodings = []string{"br", "gzip", "deflate"}
+	}
+	if config.ExcludedContentTypes == nil {
+		config.ExcludedContentTypes = defaultExcludedContentTypes
+	}
+	if config.MaxDecompressedSize <= 0 {
+		config.MaxDecompressedSize = defaultMaxDecompressedSize
+	}
+	pools := make(map[string]*sync.Pool)
+	var supported []string
+	encodingsMu.RLock()
+	for _, name := range config.Encodings {
+		fn, ok := encodings[name]
+		if !ok {
+			continue
+		}
+		if _, err := fn(io.Discard, config.Level); err != nil {
+			encodingsMu.RUnlock()
+			panic(fmt.Sprintf("msgo: compress %s: %v", name, err))
+		}
+		supported = append(supported, name)
+		level := config.Level
+		pools[name] = &sync.Pool{New: func() any {
+			w, _ := fn(io.Discard, level)
+			return w
+		}}
+	}
+	encodingsMu.RUnlock()
+
+	return func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			if err := decompressRequestBody(ctx.R, config.MaxDecompressedSize); err != nil {
+				ctx.W.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(ctx.W, "%s invalid request body: %v \n", ctx.R.RequestURI, err)
+				return
+			}
+			for _, prefix := range config.ExcludedPaths {
+				if strings.HasPrefix(ctx.R.URL.Path, prefix) {
+					next(ctx)
+					return
+				}
+			}
+			// websocket 的握手请求需要原始的连接
+			if strings.EqualFold(ctx.R.Header.Get("Upgrade"), "websocket") {
+				next(ctx)
+				return
+			}
+			w := &compressWriter{
+				ResponseWriter: ctx.W,
+				config:         &config,
+				encoding:       negotiateEncoding(ctx.R.Header.Get("Accept-Encoding"), supported),
+				method:         ctx.R.Method,
+			}
+			if w.encoding != "" {
+				w.pool = pools[w.encoding]
+			}
+			ctx.W = w
+			defer func() {
+				ctx.W = w.ResponseWriter
+			}()
+			next(ctx)
+			w.close()
+		}
+	}
+}
+
+type compressWriter struct {
+	http.ResponseWriter
+	config   *CompressConfig
+	encoding string
+	method   string
+	pool     *sync.Pool
+
+	status      int
+	wroteHeader bool
+	decided     bool
+	hijacked    bool
+	buf         []byte
+	cw          CompressWriter
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.status = code
+	w.wroteHeader = true
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < w.config.MinLength {
+			return len(b), nil
+		}
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if w.cw != nil {
+		return w.cw.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// decide 决定是否压缩 并输出状态码和已经缓冲的内容
+func (w *compressWriter) decide(largeEnough bool) error {
+	w.decided = true
+	header := w.Header()
+	if header.Get("Content-Type") == "" && len(w.buf) > 0 {
+		header.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	if w.compressible() {
+		header.Add("Vary", "Accept-Encoding")
+		if largeEnough && w.encoding != "" {
+			header.Set("Content-Encoding", w.encoding)
+			header.Del("Content-Length")
+			w.cw = w.pool.Get().(CompressWriter)
+			w.cw.Reset(w.ResponseWriter)
+		}
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.cw != nil {
+		_, err = w.cw.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+func (w *compressWriter) compressible() bool {
+	if w.method == http.MethodHead {
+		return false
+	}
+	switch {
+	case w.status < http.StatusOK, w.status == http.StatusNoContent, w.status == http.StatusNotModified,
+		w.status == http.StatusPartialContent:
+		return false
+	}
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	contentType := header.Get("Content-Type")
+	for _, excluded := range w.config.ExcludedContentTypes {
+		if strings.HasPrefix(contentType, excluded) {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *compressWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.decided {
+		_ = w.decide(true)
+	}
+	if w.cw != nil {
+		_ = w.cw.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the ResponseWriter doesn't support the Hijacker interface")
+	}
+	w.hijacked = true
+	return hijacker.Hijack()
+}
+
+func (w *compressWriter) close() {
+	if w.hijacked {
+		return
+	}
+	if !w.decided {
+		if !w.wroteHeader {
+			// handler 没有写任何内容 保持 net/http 的默认行为
+			return
+		}
+		_ = w.decide(false)
+	}
+	if w.cw != nil {
+		_ = w.cw.Close()
+		w.cw.Reset(io.Discard)
+		w.pool.Put(w.cw)
+		w.cw = nil
+	}
+}
+
+type acceptEncoding struct {
+	name string
+	q    float64
+}
+
+// negotiateEncoding 按 q 值选择客户端最想要的算法 q 值相同时按服务端的优先级
+func negotiateEncoding(header string, supported []string) string {
+	if header == "" || len(supported) == 0 {
+		return ""
+	}
+	var accepted []acceptEncoding
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(params[0]))
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		accepted = append(accepted, acceptEncoding{name: name, q: q})
+	}
+	best, bestQ, bestRank := "", 0.0, len(supported)
+	for rank, name := range supported {
+		q := -1.0
+		for _, a := range accepted {
+			if a.name == name {
+				q = a.q
+				break
+			}
+			if a.name == "*" && q < 0 {
+				q = a.q
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		if q > bestQ || (q == bestQ && rank < bestRank) {
+			best, bestQ, bestRank = name, q, rank
+		}
+	}
+	return best
+}
+
+var gzipReaderPool sync.Pool
+
+type gzipBody struct {
+	*gzip.Reader
+	body   io.ReadCloser
+	closed bool
+}
+
+func (b *gzipBody) Close() error {
+	// 多次 Close 时不能把同一个 Reader 重复放回 pool
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	err := b.body.Close()
+	gzipReaderPool.Put(b.Reader)
+	return err
+}
+
+type zlibBody struct {
+	io.ReadCloser
+	body io.ReadCloser
+}
+
+func (b *zlibBody) Close() error {
+	b.ReadCloser.Close()
+	return b.body.Close()
+}
+
+// decompressRequestBody 解压 Content-Encoding 为 gzip 或 deflate 的请求体 解压后删除该请求头
+// 解压后超过 maxSize 字节时读取返回 ErrBodyTooLarge
+func decompressRequestBody(r *http.Request, maxSize int64) error {
+	if r == nil || r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	// 只处理单一的编码 多重编码交给 handler 自己处理
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	if encoding == "" || strings.Contains(encoding, ",") || len(r.Header.Values("Content-Encoding")) > 1 {
+		return nil
+	}
+	switch encoding {
+	case "gzip", "x-gzip":
+		zr, _ := gzipReaderPool.Get().(*gzip.Reader)
+		var err error
+		if zr == nil {
+			zr, err = gzip.NewReader(r.Body)
+		} else if err = zr.Reset(r.Body); err != nil {
+			gzipReaderPool.Put(zr)
+		}
+		if err != nil {
+			return err
+		}
+		r.Body = &gzipBody{Reader: zr, body: r.Body}
+	case "deflate":
+		zr, err := zlib.NewReader(r.Body)
+		if err != nil {
+			return err
+		}
+		r.Body = &zlibBody{ReadCloser: zr, body: r.Body}
+	default:
+		return nil
+	}
+	r.Body = &limitedBody{ReadCloser: r.Body, remaining: maxSize}
+	r.Header.Del("Content-Encoding")
+	r.Header.Del("Content-Length")
+	r.ContentLength = -1
+	return nil
+}
@@ -0,0 +1,153 @@
+package msgo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	supported := []string{"br", "gzip", "deflate"}
+	cases := map[string]string{
+		"":                          "",
+		"gzip":                      "gzip",
+		"deflate, gzip":             "gzip",
+		"gzip;q=0.5, deflate":       "deflate",
+		"br;q=0, gzip;q=0":          "",
+		"*":                         "br",
+		"*;q=0.1, gzip;q=0.2":       "gzip",
+		"identity":                  "",
+		"GZIP;Q=1.0, deflate;q=0.9": "gzip",
+	}
+	for header, want := range cases {
+		if got := negotiateEncoding(header, supported); got != want {
+			t.Errorf("negotiateEncoding(%q) = %q want %q", header, got, want)
+		}
+	}
+}
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat("hello msgo ", 200)
+	engine := New()
+	g := engine.Group("user")
+	g.Use(Compress(CompressConfig{}))
+	g.Get("/large", func(ctx *Context) {
+		ctx.String(http.StatusOK, large)
+	})
+	g.Get("/small", func(ctx *Context) {
+		ctx.String(http.StatusOK, "hello")
+	})
+	g.Get("/png", func(ctx *Context) {
+		ctx.W.Header().Set("Content-Type", "image/png")
+		ctx.W.Write([]byte(large))
+	})
+
+	get := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		return w
+	}
+
+	w := get("/user/large", "gzip")
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("header = %v", w.Header())
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := io.ReadAll(zr); string(data) != large {
+		t.Fatalf("body = %q", data)
+	}
+	// 客户端不支持压缩时响应仍然需要 Vary 缓存才能区分
+	w = get("/user/large", "")
+	if w.Header().Get("Content-Encoding") != "" || w.Header().Get("Vary") != "Accept-Encoding" || w.Body.String() != large {
+		t.Fatalf("identity = %v", w.Header())
+	}
+	w = get("/user/small", "gzip")
+	if w.Header().Get("Content-Encoding") != "" || w.Body.String() != "hello" {
+		t.Fatalf("small = %v %q", w.Header(), w.Body)
+	}
+	w = get("/user/png", "gzip")
+	if w.Header().Get("Content-Encoding") != "" || w.Header().Get("Vary") != "" || w.Body.String() != large {
+		t.Fatalf("png = %v", w.Header())
+	}
+}
+
+func gzipped(t *testing.T, data []byte) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	return &buf
+}
+
+func TestDecompressRequestBody(t *testing.T) {
+	engine := New()
+	var body []byte
+	var readErr error
+	engine.Group("user").Post("/echo", func(ctx *Context) {
+		body, readErr = io.ReadAll(ctx.R.Body)
+		ctx.R.Body.Close()
+	}, Compress(CompressConfig{MaxDecompressedSize: 1024}))
+
+	post := func(data *bytes.Buffer) {
+		r := httptest.NewRequest(http.MethodPost, "/user/echo", data)
+		r.Header.Set("Content-Encoding", "gzip")
+		engine.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	post(gzipped(t, []byte("hello")))
+	if readErr != nil || string(body) != "hello" {
+		t.Fatalf("body = %q %v", body, readErr)
+	}
+	// 压缩后很小的请求体解压后超过限制
+	post(gzipped(t, make([]byte, 1<<20)))
+	if !errors.Is(readErr, ErrBodyTooLarge) || len(body) != 1024 {
+		t.Fatalf("body = %d bytes %v", len(body), readErr)
+	}
+}
+
+func TestGzipBodyCloseTwice(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", gzipped(t, []byte("hello")))
+	r.Header.Set("Content-Encoding", "gzip")
+	if err := decompressRequestBody(r, defaultMaxDecompressedSize); err != nil {
+		t.Fatal(err)
+	}
+	r.Body.Close()
+	r.Body.Close()
+	// 重复 Close 时同一个 Reader 会被放回 pool 两次 之后两次 Get 得到同一个 Reader
+	first, _ := gzipReaderPool.Get().(*gzip.Reader)
+	second, _ := gzipReaderPool.Get().(*gzip.Reader)
+	if first != nil && first == second {
+		t.Fatal("gzip reader returned to the pool twice")
+	}
+}
+
+func TestGzipReaderReturnedOnInvalidBody(t *testing.T) {
+	// -race 时 sync.Pool 会随机丢弃放回的对象 多试几次
+	for i := 0; i < 10; i++ {
+		zr, err := gzip.NewReader(gzipped(t, []byte("hello")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		gzipReaderPool.Put(zr)
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("not gzip"))
+		r.Header.Set("Content-Encoding", "gzip")
+		if err := decompressRequestBody(r, defaultMaxDecompressedSize); err == nil {
+			t.Fatal("invalid gzip body accepted")
+		}
+		// Reset 失败时从 pool 取出的 Reader 需要放回去
+		if got, _ := gzipReaderPool.Get().(*gzip.Reader); got == zr {
+			return
+		}
+	}
+	t.Fatal("gzip reader not returned to the pool")
+}
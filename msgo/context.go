@@ -1,33 +1,217 @@
 package msgo
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/demo-go/msgo/render"
-	"html/template"
+	"github.com/demo-go/msgo/sessions"
 	"io"
+	"io/fs"
 	"log"
-	"mime/multipart"
 	"net/http"
 	"net/url"
-	"os"
 	"reflect"
 	"strings"
+	"sync"
+	"time"
 )
 
-const defaultMultipartMemory = 32 << 20
-
+// Context 实现了 context.Context 可以直接传给数据库或 RPC 调用
+// 注意 Context 会被放回 pool 复用 handler 返回后不能再使用
 type Context struct {
 	W                     http.ResponseWriter
 	R                     *http.Request
 	engine                *Engine
+	fullPath              string
+	mu                    sync.RWMutex
+	keys                  map[string]any // 中间件和 handler 之间传递的数据
+	upload                *UploadConfig
+	session               *sessions.Session
+	wroteHeader           bool // Render 已经写入了状态码
 	queryCache            url.Values
 	formCache             url.Values
 	DisallowUnknownFields bool
 	IsValidate            bool
 }
 
+var _ context.Context = (*Context)(nil)
+
+// Deadline 委托给 c.R.Context() 没有请求时和 context.Background() 相同
+func (c *Context) Deadline() (deadline time.Time, ok bool) {
+	if c.R == nil {
+		return
+	}
+	return c.R.Context().Deadline()
+}
+
+// Done 客户端断开连接 请求超时或者服务关闭时被关闭
+func (c *Context) Done() <-chan struct{} {
+	if c.R == nil {
+		return nil
+	}
+	return c.R.Context().Done()
+}
+
+func (c *Context) Err() error {
+	if c.R == nil {
+		return nil
+	}
+	return c.R.Context().Err()
+}
+
+// Value 字符串类型的 key 先从 Set 保存的数据中查找 再委托给 c.R.Context()
+func (c *Context) Value(key any) any {
+	if k, ok := key.(string); ok {
+		if value, exists := c.Get(k); exists {
+			return value
+		}
+	}
+	if c.R == nil {
+		return nil
+	}
+	return c.R.Context().Value(key)
+}
+
+// reset 请求开始前清空上一个请求留下的数据
+func (c *Context) reset(w http.ResponseWriter, r *http.Request) {
+	c.W = w
+	c.R = r
+	c.fullPath = ""
+	c.queryCache = nil
+	c.formCache = nil
+	c.upload = nil
+	c.session = nil
+	c.wroteHeader = false
+	c.mu.Lock()
+	c.keys = nil
+	c.mu.Unlock()
+}
+
+// Set 保存一个只在当前请求中有效的值 例如认证中间件保存当前用户 可以在多个 goroutine 中调用
+func (c *Context) Set(key string, value any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.keys == nil {
+		c.keys = make(map[string]any)
+	}
+	c.keys[key] = value
+}
+
+func (c *Context) Get(key string) (value any, exists bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	value, exists = c.keys[key]
+	return
+}
+
+// MustGet key 不存在时 panic
+func (c *Context) MustGet(key string) any {
+	if value, exists := c.Get(key); exists {
+		return value
+	}
+	panic("msgo: key \"" + key + "\" does not exist")
+}
+
+// 下面的 GetXxx 在 key 不存在或者类型不匹配时返回零值
+
+func (c *Context) GetString(key string) (s string) {
+	if value, ok := c.Get(key); ok && value != nil {
+		s, _ = value.(string)
+	}
+	return
+}
+
+func (c *Context) GetBool(key string) (b bool) {
+	if value, ok := c.Get(key); ok && value != nil {
+		b, _ = value.(bool)
+	}
+	return
+}
+
+func (c *Context) GetInt(key string) (i int) {
+	if value, ok := c.Get(key); ok && value != nil {
+		i, _ = value.(int)
+	}
+	return
+}
+
+func (c *Context) GetInt64(key string) (i int64) {
+	if value, ok := c.Get(key); ok && value != nil {
+		i, _ = value.(int64)
+	}
+	return
+}
+
+func (c *Context) GetUint(key string) (u uint) {
+	if value, ok := c.Get(key); ok && value != nil {
+		u, _ = value.(uint)
+	}
+	return
+}
+
+func (c *Context) GetUint64(key string) (u uint64) {
+	if value, ok := c.Get(key); ok && value != nil {
+		u, _ = value.(uint64)
+	}
+	return
+}
+
+func (c *Context) GetFloat64(key string) (f float64) {
+	if value, ok := c.Get(key); ok && value != nil {
+		f, _ = value.(float64)
+	}
+	return
+}
+
+func (c *Context) GetTime(key string) (t time.Time) {
+	if value, ok := c.Get(key); ok && value != nil {
+		t, _ = value.(time.Time)
+	}
+	return
+}
+
+func (c *Context) GetDuration(key string) (d time.Duration) {
+	if value, ok := c.Get(key); ok && value != nil {
+		d, _ = value.(time.Duration)
+	}
+	return
+}
+
+func (c *Context) GetStringSlice(key string) (ss []string) {
+	if value, ok := c.Get(key); ok && value != nil {
+		ss, _ = value.([]string)
+	}
+	return
+}
+
+func (c *Context) GetStringMap(key string) (sm map[string]any) {
+	if value, ok := c.Get(key); ok && value != nil {
+		sm, _ = value.(map[string]any)
+	}
+	return
+}
+
+func (c *Context) GetStringMapString(key string) (sms map[string]string) {
+	if value, ok := c.Get(key); ok && value != nil {
+		sms, _ = value.(map[string]string)
+	}
+	return
+}
+
+func (c *Context) GetStringMapStringSlice(key string) (smss map[string][]string) {
+	if value, ok := c.Get(key); ok && value != nil {
+		smss, _ = value.(map[string][]string)
+	}
+	return
+}
+
+// FullPath 返回匹配到的路由 例如 /user/get/:id 没有匹配到路由时返回空字符串
+func (c *Context) FullPath() string {
+	return c.fullPath
+}
+
 func (c *Context) GetDefaultQuery(key, defaultValue string) string {
 	values, ok := c.GetQueryArr(key)
 	if !ok {
@@ -42,18 +226,21 @@ func (c *Context) GetQuery(key string) string {
 }
 
 func (c *Context) QueryArr(key string) []string {
-	c.initQueryCache()
-	values, _ := c.queryCache[key]
+	values, _ := c.GetQueryArr(key)
 	return values
 }
 
+// GetQueryArr 同时支持 tags=a&tags=b 和 tags[]=a&tags[]=b
 func (c *Context) GetQueryArr(key string) ([]string, bool) {
 	c.initQueryCache()
-	values, ok := c.queryCache[key]
-	return values, ok
+	return getArr(c.queryCache, key)
 }
 
+// initQueryCache 每个请求只解析一次查询参数 reset 时清空
 func (c *Context) initQueryCache() {
+	if c.queryCache != nil {
+		return
+	}
 	if c.R != nil {
 		c.queryCache = c.R.URL.Query()
 	} else {
@@ -71,37 +258,155 @@ func (c *Context) GetQueryMap(key string) (map[string]string, bool) {
 	return c.get(c.queryCache, key)
 }
 
+// GetQueryNestedMap 解析多层的查询参数 例如 user[address][city]=北京&user[tags][]=a&user[tags][]=b
+// 得到 {"address": {"city": "北京"}, "tags": ["a", "b"]}
+func (c *Context) GetQueryNestedMap(key string) (map[string]any, bool) {
+	c.initQueryCache()
+	return c.getNested(c.queryCache, key)
+}
+
 func (c *Context) get(cache map[string][]string, key string) (map[string]string, bool) {
 	// user[id]=1&user[name]=张三
+	// key 可以是多层 user[address] 匹配 user[address][city]=北京
+	// 更深的层级保留剩下的部分作为 key 例如 GetQueryMap("user") 得到 address[city]
+	base, path, ok := parseBracketKey(key)
+	if !ok {
+		return nil, false
+	}
 	dict := make(map[string]string)
 	exist := false
 	for k, value := range cache {
-		if i := strings.IndexByte(k, '['); i >= 1 && k[0:i] == key {
-			if j := strings.IndexByte(k[i+1:], ']'); j >= 1 {
-				exist = true
-				dict[k[i+1:][:j]] = value[0]
+		kBase, kPath, ok := parseBracketKey(k)
+		if !ok || kBase != base || len(kPath) <= len(path) || !hasPrefix(kPath, path) || len(value) == 0 {
+			continue
+		}
+		rest := kPath[len(path):]
+		// 数组取第一个值 user[tags][]=a 得到 tags 完整的数组用 GetQueryArr("user[tags]")
+		if rest[len(rest)-1] == "" {
+			rest = rest[:len(rest)-1]
+		}
+		if len(rest) == 0 {
+			continue
+		}
+		exist = true
+		name := rest[0]
+		for _, segment := range rest[1:] {
+			name += "[" + segment + "]"
+		}
+		if _, exists := dict[name]; !exists {
+			dict[name] = value[0]
+		}
+	}
+	return dict, exist
+}
+
+// getNested 把 user[address][city]=北京&user[tags][]=a 解析成嵌套的 map
+// 值为 string []string 或 map[string]any
+func (c *Context) getNested(cache map[string][]string, key string) (map[string]any, bool) {
+	base, path, ok := parseBracketKey(key)
+	if !ok {
+		return nil, false
+	}
+	dict := make(map[string]any)
+	exist := false
+	for k, values := range cache {
+		kBase, kPath, ok := parseBracketKey(k)
+		if !ok || kBase != base || len(kPath) <= len(path) || !hasPrefix(kPath, path) || len(values) == 0 {
+			continue
+		}
+		rest := kPath[len(path):]
+		if rest[0] == "" {
+			continue
+		}
+		exist = true
+		node := dict
+		for i, segment := range rest {
+			last := i == len(rest)-1
+			if last {
+				node[segment] = values[0]
+				break
 			}
+			if rest[i+1] == "" && i+1 == len(rest)-1 {
+				node[segment] = values
+				break
+			}
+			child, ok := node[segment].(map[string]any)
+			if !ok {
+				child = make(map[string]any)
+				node[segment] = child
+			}
+			node = child
 		}
 	}
 	return dict, exist
 }
+
+// getArr 查找 key 不存在时查找 key[]
+func getArr(cache map[string][]string, key string) ([]string, bool) {
+	if values, ok := cache[key]; ok {
+		return values, true
+	}
+	values, ok := cache[key+"[]"]
+	return values, ok
+}
+
+// parseBracketKey 把 user[address][city] 拆分为 user 和 [address city] tags[] 的路径为 [""]
+func parseBracketKey(key string) (base string, path []string, ok bool) {
+	i := strings.IndexByte(key, '[')
+	if i < 0 {
+		return key, nil, key != ""
+	}
+	if i == 0 {
+		return "", nil, false
+	}
+	base, rest := key[:i], key[i:]
+	for rest != "" {
+		if rest[0] != '[' {
+			return "", nil, false
+		}
+		j := strings.IndexByte(rest, ']')
+		if j < 0 {
+			return "", nil, false
+		}
+		path = append(path, rest[1:j])
+		rest = rest[j+1:]
+	}
+	return base, path, true
+}
+
+func hasPrefix(path, prefix []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if path[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// initFormCache 每个请求只解析一次请求体 只包含请求体中的参数 不包含查询参数
 func (c *Context) initFormCache() {
+	if c.formCache != nil {
+		return
+	}
 	if c.R != nil {
-		if err := c.R.ParseMultipartForm(defaultMultipartMemory); err != nil {
+		if err := c.R.ParseMultipartForm(c.maxMultipartMemory()); err != nil {
 			if !errors.Is(err, http.ErrNotMultipart) {
 				log.Println(err)
 			}
 		}
 		c.formCache = c.R.PostForm
-	} else {
+	}
+	if c.formCache == nil {
 		c.formCache = url.Values{}
 	}
 }
 
 func (c *Context) GetPostFormArr(key string) ([]string, bool) {
 	c.initFormCache()
-	values, ok := c.formCache[key]
-	return values, ok
+	return getArr(c.formCache, key)
 }
 
 func (c *Context) GetPostFormMap(key string) (map[string]string, bool) {
@@ -109,6 +414,11 @@ func (c *Context) GetPostFormMap(key string) (map[string]string, bool) {
 	return c.get(c.formCache, key)
 }
 
+func (c *Context) GetPostFormNestedMap(key string) (map[string]any, bool) {
+	c.initFormCache()
+	return c.getNested(c.formCache, key)
+}
+
 func (c *Context) GetPostForm(key string) (string, bool) {
 	if values, ok := c.GetPostFormArr(key); ok {
 		return values[0], ok
@@ -117,47 +427,15 @@ func (c *Context) GetPostForm(key string) (string, bool) {
 }
 
 func (c *Context) PostFormArr(key string) []string {
-	values, _ := c.GetQueryArr(key)
+	values, _ := c.GetPostFormArr(key)
 	return values
 }
 
-func (c *Context) FormFile(name string) *multipart.FileHeader {
-	file, header, err := c.R.FormFile(name)
-	if err != nil {
-		log.Println(err)
+func (c *Context) GetDefaultPostForm(key, defaultValue string) string {
+	if value, ok := c.GetPostForm(key); ok {
+		return value
 	}
-	defer file.Close()
-	return header
-}
-
-func (c *Context) FormFiles(name string) []*multipart.FileHeader {
-	multipartForm, err := c.MultipartForm()
-	if err != nil {
-		log.Println(err)
-	}
-	return multipartForm.File[name]
-}
-
-func (c *Context) SaveUploadFile(file *multipart.FileHeader, dst string) error {
-	src, err := file.Open()
-	if err != nil {
-		return err
-	}
-	defer src.Close()
-
-	out, err := os.Create(dst)
-	if err != nil {
-		return err
-	}
-	defer out.Close()
-
-	_, err = io.Copy(out, src)
-	return err
-}
-
-func (c *Context) MultipartForm() (*multipart.Form, error) {
-	err := c.R.ParseMultipartForm(defaultMultipartMemory)
-	return c.R.MultipartForm, err
+	return defaultValue
 }
 
 func (c *Context) HTML(status int, html string) error {
@@ -170,34 +448,63 @@ func (c *Context) HTML(status int, html string) error {
 func (c *Context) HTMLTemplate(name string, data any, fileNames ...string) error {
 	// 设置状态是200 默认不设置如果调用了 write 这个方法 实际上默认返回 200
 	c.W.Header().Set("Content-Type", "text/html; charset=utf-8")
-	t := template.New(name)
-	t, err := t.ParseFiles(fileNames...)
+	span := c.startSpan("template " + name)
+	defer span.End()
+	// release 模式下解析结果会被缓存 debug 模式下文件修改后重新解析
+	t, err := c.engine.templateSet(nil, name, fileNames, nil).Template()
 	if err != nil {
+		span.RecordError(err)
 		return err
 	}
 	err = t.Execute(c.W, data)
+	span.RecordError(err)
 	return err
 }
 
 func (c *Context) HTMLTemplateGlob(name string, data any, pattern string) error {
 	// 设置状态是200 默认不设置如果调用了 write 这个方法 实际上默认返回 200
 	c.W.Header().Set("Content-Type", "text/html; charset=utf-8")
-	t := template.New(name)
-	t, err := t.ParseGlob(pattern)
+	span := c.startSpan("template " + name)
+	defer span.End()
+	t, err := c.engine.templateSet(nil, name, nil, []string{pattern}).Template()
 	if err != nil {
+		span.RecordError(err)
 		return err
 	}
 	err = t.Execute(c.W, data)
+	span.RecordError(err)
+	return err
+}
+
+// HTMLTemplateFS 从 fsys 中按 patterns 加载模板 并执行名为 name 的模板
+func (c *Context) HTMLTemplateFS(name string, data any, fsys fs.FS, patterns ...string) error {
+	c.W.Header().Set("Content-Type", "text/html; charset=utf-8")
+	span := c.startSpan("template " + name)
+	defer span.End()
+	t, err := c.engine.templateSet(fsys, name, nil, patterns).Template()
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	err = t.Execute(c.W, data)
+	span.RecordError(err)
 	return err
 }
 
 func (c *Context) Template(name string, data any) error {
-	return c.Render(http.StatusOK, &render.HTML{
-		Name:       name,
-		Data:       data,
-		IsTemplate: true,
-		Template:   c.engine.HTMLRender.Template,
-	})
+	if c.engine.HTMLRender == nil {
+		return errors.New("no html templates loaded, call LoadTemplate first")
+	}
+	span := c.startSpan("template " + name)
+	defer span.End()
+	r, err := c.engine.HTMLRender.Instance(name, data)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	err = c.Render(http.StatusOK, r)
+	span.RecordError(err)
+	return err
 }
 
 func (c *Context) JSON(status int, data any) error {
@@ -234,6 +541,11 @@ func (c *Context) FileFromFS(filepath string, fs http.FileSystem) {
 	http.FileServer(fs).ServeHTTP(c.W, c.R)
 }
 
+// FileFS 从任意 fs.FS 中返回文件 例如 embed.FS
+func (c *Context) FileFS(filepath string, fsys fs.FS) {
+	c.FileFromFS(filepath, http.FS(fsys))
+}
+
 func (c *Context) Redirect(status int, url string) error {
 	return c.Render(status, &render.Redirect{
 		Code:     status,
@@ -249,20 +561,66 @@ func (c *Context) String(status int, format string, values ...any) error {
 	})
 }
 
+func (c *Context) SSEvent(name string, data any) error {
+	return c.Render(http.StatusOK, &render.SSEvent{
+		Event: name,
+		Data:  data,
+	})
+}
+
+// Stream 循环调用 step 每次调用后刷新缓冲区 step 返回 false 或客户端断开时结束
+// 返回值表示是否是客户端断开导致的结束 Timeout 中间件会缓冲响应 不能和它一起使用
+func (c *Context) Stream(step func(w io.Writer) bool) bool {
+	clientGone := c.Done()
+	for {
+		select {
+		case <-clientGone:
+			return true
+		default:
+			keepOpen := step(c.W)
+			c.Flush()
+			if !keepOpen {
+				return false
+			}
+		}
+	}
+}
+
+func (c *Context) Flush() {
+	if f, ok := c.W.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 func (c *Context) Render(statusCode int, r render.Render) error {
-	err := r.Render(c.W)
-	if statusCode != http.StatusOK {
+	// 状态码必须在写入 body 之前设置 重定向由 http.Redirect 自己写状态码
+	// 响应头已经写入时跳过 例如 SSEvent 多次调用 Render
+	if _, ok := r.(*render.Redirect); !ok && !c.headerWritten() {
+		r.WriteContentType(c.W)
 		c.W.WriteHeader(statusCode)
+		c.wroteHeader = true
 	}
-	return err
+	return r.Render(c.W)
+}
+
+// headerWritten 状态码是否已经写入 c.W 记录了状态码时以它为准
+func (c *Context) headerWritten() bool {
+	if w, ok := c.W.(interface{ Written() bool }); ok && w.Written() {
+		return true
+	}
+	return c.wroteHeader
 }
 
 func (c *Context) DealJson(obj any) error {
-	body := c.R.Body
 	// Post 传参的内容在body中
-	if body == nil {
+	if c.R.Body == nil {
 		return errors.New("invalid request")
 	}
+	// 没有使用 Compress 中间件时 这里同样支持 gzip 压缩的请求体
+	if err := decompressRequestBody(c.R, defaultMaxDecompressedSize); err != nil {
+		return err
+	}
+	body := c.R.Body
 	decoder := json.NewDecoder(body)
 	if c.DisallowUnknownFields {
 		decoder.DisallowUnknownFields()
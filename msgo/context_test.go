@@ -0,0 +1,175 @@
+package msgo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestContextImplementsContext(t *testing.T) {
+	engine := New()
+	g := engine.Group("user")
+	g.Use(RequestID(RequestIDConfig{}))
+	g.Use(Timeout(TimeoutConfig{Timeout: time.Second}))
+	var (
+		deadline  time.Time
+		ok        bool
+		requestID any
+		err       error
+	)
+	g.Get("/ctx", func(ctx *Context) {
+		deadline, ok = ctx.Deadline()
+		requestID = ctx.Value(requestIDKey{})
+		// 可以直接传给需要 context.Context 的函数
+		c, cancel := context.WithCancel(ctx)
+		cancel()
+		<-c.Done()
+		err = ctx.Err()
+	})
+	r := httptest.NewRequest(http.MethodGet, "/user/ctx", nil)
+	r.Header.Set(HeaderXRequestID, "req-1")
+	engine.ServeHTTP(httptest.NewRecorder(), r)
+
+	if !ok || time.Until(deadline) > time.Second {
+		t.Fatalf("deadline = %v %v", deadline, ok)
+	}
+	if requestID != "req-1" {
+		t.Fatalf("request id = %v", requestID)
+	}
+	if err != nil {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestContextKeys(t *testing.T) {
+	engine := New()
+	g := engine.Group("user")
+	g.Use(func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			if ctx.R.Header.Get("Authorization") != "" {
+				ctx.Set("user", "alice")
+				ctx.Set("roles", []string{"admin"})
+				ctx.Set("uid", 42)
+				ctx.Set("login", time.Unix(0, 0))
+			}
+			next(ctx)
+		}
+	})
+	var got []any
+	g.Get("/me", func(ctx *Context) {
+		_, exists := ctx.Get("user")
+		got = []any{exists, ctx.GetString("user"), ctx.GetStringSlice("roles"), ctx.GetInt("uid"),
+			ctx.GetInt64("uid"), ctx.GetTime("login").Unix(), ctx.Value("user")}
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/user/me", nil)
+	r.Header.Set("Authorization", "token")
+	engine.ServeHTTP(httptest.NewRecorder(), r)
+	if got[0] != true || got[1] != "alice" || got[2].([]string)[0] != "admin" || got[3] != 42 ||
+		got[4] != int64(0) || got[5] != int64(0) || got[6] != "alice" {
+		t.Fatalf("got %v", got)
+	}
+
+	// Context 放回 pool 后再次使用时不能看到上一个请求的数据
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/user/me", nil))
+	if got[0] != false || got[1] != "" || got[6] != nil {
+		t.Fatalf("keys leaked between requests: %v", got)
+	}
+}
+
+func TestMustGetPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("MustGet should panic")
+		}
+	}()
+	(&Context{}).MustGet("missing")
+}
+
+func TestNestedQuery(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet,
+		"/user/q?user[name]=dema&user[address][city]=beijing&user[address][zip]=100000&user[tags][]=a&user[tags][]=b&ids[]=1&ids[]=2", nil)
+	ctx := &Context{R: r}
+
+	user, ok := ctx.GetQueryMap("user")
+	if !ok || user["name"] != "dema" || user["address[city]"] != "beijing" || user["tags"] != "a" {
+		t.Fatalf("user = %v", user)
+	}
+	address, ok := ctx.GetQueryMap("user[address]")
+	if !ok || len(address) != 2 || address["city"] != "beijing" || address["zip"] != "100000" {
+		t.Fatalf("address = %v", address)
+	}
+	if tags := ctx.QueryArr("user[tags]"); len(tags) != 2 || tags[1] != "b" {
+		t.Fatalf("tags = %v", tags)
+	}
+	if ids := ctx.QueryArr("ids"); len(ids) != 2 {
+		t.Fatalf("ids = %v", ids)
+	}
+
+	nested, ok := ctx.GetQueryNestedMap("user")
+	if !ok || nested["name"] != "dema" {
+		t.Fatalf("nested = %v", nested)
+	}
+	if city := nested["address"].(map[string]any)["city"]; city != "beijing" {
+		t.Fatalf("city = %v", city)
+	}
+	if tags := nested["tags"].([]string); len(tags) != 2 {
+		t.Fatalf("tags = %v", tags)
+	}
+}
+
+func TestQueryCacheReset(t *testing.T) {
+	engine := New()
+	var names []string
+	engine.Group("user").Get("/name", func(ctx *Context) {
+		names = append(names, ctx.GetQuery("name"))
+		// 同一个请求中只解析一次
+		ctx.R.URL.RawQuery = "name=changed"
+		names = append(names, ctx.GetQuery("name"))
+	})
+	for _, name := range []string{"a", "b"} {
+		engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/user/name?name="+name, nil))
+	}
+	if len(names) != 4 || names[0] != "a" || names[1] != "a" || names[2] != "b" || names[3] != "b" {
+		t.Fatalf("names = %v", names)
+	}
+}
+
+// headerCounter 记录 WriteHeader 被调用的次数
+type headerCounter struct {
+	*httptest.ResponseRecorder
+	calls int
+}
+
+func (w *headerCounter) WriteHeader(code int) {
+	w.calls++
+	w.ResponseRecorder.WriteHeader(code)
+}
+
+func TestRenderWritesHeaderOnce(t *testing.T) {
+	engine := New()
+	g := engine.Group("user")
+	g.Get("/events", func(ctx *Context) {
+		for i := 0; i < 3; i++ {
+			ctx.SSEvent("tick", i)
+		}
+	})
+	// 经过 Metrics 中间件时 handler 直接写入的状态码也能被发现
+	g.Get("/denied", func(ctx *Context) {
+		ctx.W.WriteHeader(http.StatusUnauthorized)
+		ctx.String(http.StatusOK, "denied")
+	}, Metrics(MetricsConfig{}))
+
+	w := &headerCounter{ResponseRecorder: httptest.NewRecorder()}
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/user/events", nil))
+	if w.calls != 1 || w.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("WriteHeader calls = %d content type = %q", w.calls, w.Header().Get("Content-Type"))
+	}
+	w = &headerCounter{ResponseRecorder: httptest.NewRecorder()}
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/user/denied", nil))
+	if w.calls != 1 || w.Code != http.StatusUnauthorized || w.Body.String() != "denied" {
+		t.Fatalf("WriteHeader calls = %d code = %d", w.calls, w.Code)
+	}
+}
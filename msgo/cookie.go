@@ -0,0 +1,184 @@
+package msgo
+
+import (
+	"errors"
+	"github.com/demo-go/msgo/internal/securecookie"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidCookie 签名或者加密的 cookie 无法验证 可能被篡改或者使用了已经移除的密钥
+	ErrInvalidCookie = securecookie.ErrInvalid
+	// ErrCookieExpired 签名或者加密的 cookie 超过了设置时的 MaxAge
+	ErrCookieExpired = securecookie.ErrExpired
+	// ErrNoCookieKeys 没有调用 Engine.SetCookieKeys
+	ErrNoCookieKeys = errors.New("msgo: cookie keys not set")
+)
+
+type CookieOptions struct {
+	Path   string
+	Domain string
+	// MaxAge 为 0 时是会话 cookie 小于 0 时删除 cookie
+	MaxAge int
+	// Secure 为 false 时 HTTPS 请求设置的 cookie 仍然带有 Secure
+	Secure   bool
+	HttpOnly bool
+	SameSite http.SameSite
+}
+
+// DefaultCookieOptions Engine.CookieOptions 为 nil 时使用
+var DefaultCookieOptions = CookieOptions{
+	Path:     "/",
+	HttpOnly: true,
+	SameSite: http.SameSiteLaxMode,
+}
+
+func (c *Context) cookieOptions(options []CookieOptions) CookieOptions {
+	if len(options) > 0 {
+		return options[0]
+	}
+	if c.engine != nil && c.engine.CookieOptions != nil {
+		return *c.engine.CookieOptions
+	}
+	return DefaultCookieOptions
+}
+
+// Cookie 返回名为 name 的 cookie 的原始值 不做任何解码 不存在时返回 http.ErrNoCookie
+func (c *Context) Cookie(name string) (string, error) {
+	cookie, err := c.R.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return cookie.Value, nil
+}
+
+// SetCookie 原样设置 cookie 的值 值中不合法的字符会被 net/http 丢弃
+// 需要保存任意内容时先用 url.QueryEscape 编码 读取时再解码
+// 不传 options 时使用 Engine.CookieOptions 或者 DefaultCookieOptions
+func (c *Context) SetCookie(name, value string, options ...CookieOptions) {
+	c.setCookie(name, value, c.cookieOptions(options))
+}
+
+// DeleteCookie 让浏览器删除 cookie Path 和 Domain 需要和设置时相同
+func (c *Context) DeleteCookie(name string, options ...CookieOptions) {
+	opts := c.cookieOptions(options)
+	opts.MaxAge = -1
+	c.setCookie(name, "", opts)
+}
+
+func (c *Context) setCookie(name, value string, options CookieOptions) {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     options.Path,
+		Domain:   options.Domain,
+		MaxAge:   options.MaxAge,
+		Secure:   options.Secure || c.isHTTPS(),
+		HttpOnly: options.HttpOnly,
+		SameSite: options.SameSite,
+	}
+	if cookie.Path == "" {
+		cookie.Path = "/"
+	}
+	if cookie.SameSite == http.SameSiteDefaultMode {
+		cookie.SameSite = http.SameSiteLaxMode
+	}
+	// 浏览器拒绝没有 Secure 的 SameSite=None
+	if cookie.SameSite == http.SameSiteNoneMode {
+		cookie.Secure = true
+	}
+	if cookie.MaxAge > 0 {
+		cookie.Expires = time.Now().Add(time.Duration(cookie.MaxAge) * time.Second)
+	} else if cookie.MaxAge < 0 {
+		cookie.Expires = time.Unix(1, 0)
+	}
+	http.SetCookie(c.W, cookie)
+}
+
+// isHTTPS 请求是否通过 HTTPS 发送 可信代理可以通过 X-Forwarded-Proto 告知
+func (c *Context) isHTTPS() bool {
+	if c.R.TLS != nil {
+		return true
+	}
+	if c.engine == nil || !strings.EqualFold(c.R.Header.Get("X-Forwarded-Proto"), "https") {
+		return false
+	}
+	ip := remoteIP(c.R)
+	return ip != nil && c.engine.isTrustedProxy(ip)
+}
+
+// SetCookieKeys 设置签名和加密 cookie 使用的密钥 每个密钥至少 32 字节
+// 第一个密钥用于签名和加密 其余的密钥只用于验证和解密 轮换密钥时把新密钥放在最前面 旧密钥保留一段时间后再移除
+func (e *Engine) SetCookieKeys(keys ...[]byte) error {
+	codec, err := securecookie.New(keys...)
+	if err != nil {
+		return err
+	}
+	e.cookieCodec = codec
+	return nil
+}
+
+func (c *Context) cookieCodec() (*securecookie.Codec, error) {
+	if c.engine == nil || c.engine.cookieCodec == nil {
+		return nil, ErrNoCookieKeys
+	}
+	return c.engine.cookieCodec, nil
+}
+
+// SetSignedCookie 设置带有 HMAC-SHA256 签名的 cookie 客户端可以看到值 但不能修改
+// 签名包含 cookie 的名字 不能把一个 cookie 的值复制给另一个 cookie
+// MaxAge 大于 0 时过期时间也会被签名 客户端不能延长有效期
+func (c *Context) SetSignedCookie(name, value string, options ...CookieOptions) error {
+	codec, err := c.cookieCodec()
+	if err != nil {
+		return err
+	}
+	opts := c.cookieOptions(options)
+	c.setCookie(name, codec.Sign(name, []byte(value), securecookie.ExpiresIn(opts.MaxAge)), opts)
+	return nil
+}
+
+// SignedCookie 验证并返回 SetSignedCookie 设置的值 任意一个密钥验证通过即可
+func (c *Context) SignedCookie(name string) (string, error) {
+	codec, err := c.cookieCodec()
+	if err != nil {
+		return "", err
+	}
+	cookie, err := c.R.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	value, err := codec.Verify(name, cookie.Value)
+	return string(value), err
+}
+
+// SetEncryptedCookie 设置使用 AES-GCM 加密的 cookie 客户端既不能看到也不能修改值
+func (c *Context) SetEncryptedCookie(name, value string, options ...CookieOptions) error {
+	codec, err := c.cookieCodec()
+	if err != nil {
+		return err
+	}
+	opts := c.cookieOptions(options)
+	encrypted, err := codec.Encrypt(name, []byte(value), securecookie.ExpiresIn(opts.MaxAge))
+	if err != nil {
+		return err
+	}
+	c.setCookie(name, encrypted, opts)
+	return nil
+}
+
+// EncryptedCookie 解密并返回 SetEncryptedCookie 设置的值 依次尝试每个密钥
+func (c *Context) EncryptedCookie(name string) (string, error) {
+	codec, err := c.cookieCodec()
+	if err != nil {
+		return "", err
+	}
+	cookie, err := c.R.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	value, err := codec.Decrypt(name, cookie.Value)
+	return string(value), err
+}
@@ -0,0 +1,129 @@
+package msgo
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// roundTrip 把 set 设置的 cookie 带到新的请求中 再交给 get 读取
+func roundTrip(engine *Engine, set func(ctx *Context), get func(ctx *Context)) {
+	w := httptest.NewRecorder()
+	set(&Context{W: w, R: httptest.NewRequest(http.MethodGet, "/", nil), engine: engine})
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, cookie := range w.Result().Cookies() {
+		r.AddCookie(cookie)
+	}
+	get(&Context{W: httptest.NewRecorder(), R: r, engine: engine})
+}
+
+func TestSetCookieDefaults(t *testing.T) {
+	w := httptest.NewRecorder()
+	ctx := &Context{W: w, R: httptest.NewRequest(http.MethodGet, "/", nil), engine: New()}
+	ctx.SetCookie("name", "dema")
+	header := w.Header().Get("Set-Cookie")
+	for _, want := range []string{"Path=/", "HttpOnly", "SameSite=Lax"} {
+		if !strings.Contains(header, want) {
+			t.Fatalf("Set-Cookie %q missing %s", header, want)
+		}
+	}
+	if strings.Contains(header, "Secure") {
+		t.Fatalf("Secure on plain HTTP: %q", header)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.TLS = &tls.ConnectionState{}
+	w = httptest.NewRecorder()
+	ctx = &Context{W: w, R: r, engine: New()}
+	ctx.SetCookie("name", "v", CookieOptions{SameSite: http.SameSiteNoneMode})
+	if header := w.Header().Get("Set-Cookie"); !strings.Contains(header, "Secure") || !strings.Contains(header, "SameSite=None") {
+		t.Fatalf("Set-Cookie = %q", header)
+	}
+
+	roundTrip(New(), func(ctx *Context) {
+		ctx.SetCookie("name", "dema")
+		ctx.SetCookie("query", url.QueryEscape("张三 a=b;c"))
+	}, func(ctx *Context) {
+		if value, err := ctx.Cookie("name"); value != "dema" || err != nil {
+			t.Fatalf("cookie = %q %v", value, err)
+		}
+		// 读取和设置对称 不会自动解码
+		if value, _ := ctx.Cookie("query"); value != url.QueryEscape("张三 a=b;c") {
+			t.Fatalf("cookie = %q", value)
+		}
+		if _, err := ctx.Cookie("missing"); !errors.Is(err, http.ErrNoCookie) {
+			t.Fatalf("err = %v", err)
+		}
+	})
+}
+
+func TestSignedAndEncryptedCookie(t *testing.T) {
+	oldKey := bytes.Repeat([]byte("o"), 32)
+	newKey := bytes.Repeat([]byte("n"), 32)
+	engine := New()
+	if err := engine.SetCookieKeys([]byte("short")); err == nil {
+		t.Fatal("short key should be rejected")
+	}
+	if err := engine.SetCookieKeys(oldKey); err != nil {
+		t.Fatal(err)
+	}
+	var signed, encrypted string
+	roundTrip(engine, func(ctx *Context) {
+		ctx.SetSignedCookie("user", "dema")
+		ctx.SetEncryptedCookie("token", "secret")
+	}, func(ctx *Context) {
+		if value, err := ctx.SignedCookie("user"); value != "dema" || err != nil {
+			t.Fatalf("signed = %q %v", value, err)
+		}
+		if value, err := ctx.EncryptedCookie("token"); value != "secret" || err != nil {
+			t.Fatalf("encrypted = %q %v", value, err)
+		}
+		c, _ := ctx.R.Cookie("user")
+		signed = c.Value
+		c, _ = ctx.R.Cookie("token")
+		encrypted = c.Value
+	})
+	if strings.Contains(encrypted, "secret") {
+		t.Fatal("encrypted cookie leaks value")
+	}
+
+	get := func(name, value string) *Context {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(&http.Cookie{Name: name, Value: value})
+		return &Context{R: r, engine: engine}
+	}
+	// 轮换密钥后 旧密钥签名的 cookie 仍然有效
+	if err := engine.SetCookieKeys(newKey, oldKey); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := get("user", signed).SignedCookie("user"); value != "dema" || err != nil {
+		t.Fatalf("rotated signed = %q %v", value, err)
+	}
+	if value, err := get("token", encrypted).EncryptedCookie("token"); value != "secret" || err != nil {
+		t.Fatalf("rotated encrypted = %q %v", value, err)
+	}
+	// 篡改 或者复制给另一个名字的 cookie 都无法通过验证
+	tampered := "YWRtaW4" + signed[strings.IndexByte(signed, '.'):]
+	if _, err := get("user", tampered).SignedCookie("user"); !errors.Is(err, ErrInvalidCookie) {
+		t.Fatalf("tampered err = %v", err)
+	}
+	if _, err := get("admin", signed).SignedCookie("admin"); !errors.Is(err, ErrInvalidCookie) {
+		t.Fatalf("renamed err = %v", err)
+	}
+	if _, err := get("other", encrypted).EncryptedCookie("other"); !errors.Is(err, ErrInvalidCookie) {
+		t.Fatalf("renamed encrypted err = %v", err)
+	}
+	// 移除旧密钥后失效
+	engine.SetCookieKeys(newKey)
+	if _, err := get("user", signed).SignedCookie("user"); !errors.Is(err, ErrInvalidCookie) {
+		t.Fatalf("removed key err = %v", err)
+	}
+	if _, err := get("token", encrypted).EncryptedCookie("token"); !errors.Is(err, ErrInvalidCookie) {
+		t.Fatalf("removed key encrypted err = %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package msgo
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+// ErrCopiedContext 通过 Copy 得到的 Context 不能写响应
+var ErrCopiedContext = errors.New("msgo: can not write response from a copied Context")
+
+// Copy 返回当前 Context 的只读副本 可以交给 handler 返回后还在运行的 goroutine 使用
+// 副本包含请求的克隆 匹配到的路由 查询参数和 Set 保存的数据 不能读取请求体 写响应会返回 ErrCopiedContext
+// 副本的 Done 在请求结束时仍然会被关闭 后台任务不应该依赖它继续运行
+// 副本和原来的 Context 共享同一个会话 请求结束时会话已经保存 之后对会话的修改不会被保存
+func (c *Context) Copy() *Context {
+	r := c.R.Clone(c.R.Context())
+	r.Body = http.NoBody
+	return c.detach(&copiedWriter{header: c.W.Header().Clone()}, r)
+}
+
+// detach 返回一个不属于 pool 的 Context 副本 使用新的 ResponseWriter 和请求
+// 查询参数和表单的缓存会被复制 会话是同一个对象 sessions.Session 可以并发使用
+func (c *Context) detach(w http.ResponseWriter, r *http.Request) *Context {
+	detached := &Context{
+		W:                     w,
+		R:                     r,
+		engine:                c.engine,
+		fullPath:              c.fullPath,
+		queryCache:            cloneValues(c.queryCache),
+		formCache:             cloneValues(c.formCache),
+		upload:                c.upload,
+		session:               c.session,
+		DisallowUnknownFields: c.DisallowUnknownFields,
+		IsValidate:            c.IsValidate,
+	}
+	c.mu.RLock()
+	if c.keys != nil {
+		detached.keys = make(map[string]any, len(c.keys))
+		for k, v := range c.keys {
+			detached.keys[k] = v
+		}
+	}
+	c.mu.RUnlock()
+	return detached
+}
+
+func cloneValues(values url.Values) url.Values {
+	if values == nil {
+		return nil
+	}
+	cloned := make(url.Values, len(values))
+	for k, v := range values {
+		cloned[k] = append([]string(nil), v...)
+	}
+	return cloned
+}
+
+type copiedWriter struct {
+	header http.Header
+}
+
+func (w *copiedWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *copiedWriter) Write([]byte) (int, error) {
+	return 0, ErrCopiedContext
+}
+
+func (w *copiedWriter) WriteHeader(int) {}
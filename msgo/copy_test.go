@@ -0,0 +1,77 @@
+package msgo
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// TestCopyOutlivesRequest 需要使用 go test -race 运行
+// handler 返回后 ctx 会被放回 pool 给后面的请求复用 后台 goroutine 只能使用 Copy 得到的副本
+func TestCopyOutlivesRequest(t *testing.T) {
+	engine := New()
+	g := engine.Group("user")
+	g.Use(func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			ctx.Set("user", ctx.R.URL.Query().Get("name"))
+			next(ctx)
+		}
+	})
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	errs := make(chan error, 100)
+	g.Get("/get/:id", func(ctx *Context) {
+		cp := ctx.Copy()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// 等所有请求都处理完 pool 中的 Context 已经被反复复用
+			<-start
+			want := "user" + cp.R.URL.Path[len("/user/get/"):]
+			if got := cp.GetString("user"); got != want {
+				errs <- fmt.Errorf("user = %q want %q", got, want)
+			}
+			if got := cp.GetQuery("name"); got != want {
+				errs <- fmt.Errorf("query = %q want %q", got, want)
+			}
+			if cp.FullPath() != "/user/get/:id" {
+				errs <- fmt.Errorf("full path = %q", cp.FullPath())
+			}
+			if _, err := cp.W.Write([]byte("late")); err != ErrCopiedContext {
+				errs <- fmt.Errorf("write err = %v", err)
+			}
+		}()
+		_ = ctx.String(http.StatusOK, "ok")
+	})
+
+	var requests sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		requests.Add(1)
+		go func(i int) {
+			defer requests.Done()
+			url := fmt.Sprintf("/user/get/%d?name=user%d", i, i)
+			engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, url, nil))
+		}(i)
+	}
+	requests.Wait()
+	close(start)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestCopyClonesCaches(t *testing.T) {
+	ctx := &Context{W: httptest.NewRecorder(), R: httptest.NewRequest(http.MethodGet, "/?name=dema", nil)}
+	ctx.GetQuery("name")
+	cp := ctx.Copy()
+	// 修改原来的缓存不会影响副本
+	ctx.queryCache.Set("name", "changed")
+	if got := cp.GetQuery("name"); got != "dema" {
+		t.Fatalf("copied query = %q", got)
+	}
+}
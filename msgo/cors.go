@@ -0,0 +1,172 @@
+package msgo
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type CORSConfig struct {
+	// AllowOrigins 允许的来源 支持 "*" 以及 "https://*.example.com" 这样的通配
+	AllowOrigins []string
+	// AllowOriginFunc 不为空时 AllowOrigins 没有匹配上的来源再交给它判断
+	AllowOriginFunc  func(origin string) bool
+	AllowMethods     []string
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+var defaultCORSMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead,
+}
+
+var defaultCORSHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With"}
+
+// CORS 处理跨域请求 预检请求直接在这里应答 不会进入 handler
+// 预检请求只会经过分组中间件 没有注册 OPTIONS 的路由上 路由级别的 CORS 收不到预检请求 需要用 Group.Use 注册
+// AllowOrigins 包含 "*" 时不能设置 AllowCredentials 否则任何网站都可以带着用户的凭证访问
+func CORS(config CORSConfig) MiddlewareFunc {
+	if len(config.AllowMethods) == 0 {
+		config.AllowMethods = defaultCORSMethods
+	}
+	if len(config.AllowHeaders) == 0 {
+		config.AllowHeaders = defaultCORSHeaders
+	}
+	allowAllOrigins := false
+	for _, origin := range config.AllowOrigins {
+		if origin == "*" {
+			allowAllOrigins = true
+		}
+	}
+	if allowAllOrigins && config.AllowCredentials {
+		panic("msgo: CORSConfig.AllowOrigins \"*\" can not be used with AllowCredentials")
+	}
+	allowAllHeaders := false
+	for _, header := range config.AllowHeaders {
+		if header == "*" {
+			allowAllHeaders = true
+		}
+	}
+	allowMethods := strings.ToUpper(strings.Join(config.AllowMethods, ", "))
+	allowHeaders := strings.Join(config.AllowHeaders, ", ")
+	exposeHeaders := strings.Join(config.ExposeHeaders, ", ")
+	maxAge := ""
+	if config.MaxAge > 0 {
+		maxAge = strconv.FormatInt(int64(config.MaxAge/time.Second), 10)
+	}
+
+	originAllowed := func(origin string) bool {
+		if allowAllOrigins {
+			return true
+		}
+		for _, pattern := range config.AllowOrigins {
+			if matchOrigin(pattern, origin) {
+				return true
+			}
+		}
+		return config.AllowOriginFunc != nil && config.AllowOriginFunc(origin)
+	}
+	methodAllowed := func(method string) bool {
+		for _, m := range config.AllowMethods {
+			if strings.EqualFold(m, method) {
+				return true
+			}
+		}
+		return false
+	}
+	headersAllowed := func(requested string) bool {
+		if allowAllHeaders || requested == "" {
+			return true
+		}
+		for _, header := range strings.Split(requested, ",") {
+			header = strings.TrimSpace(header)
+			if header == "" {
+				continue
+			}
+			found := false
+			for _, allowed := range config.AllowHeaders {
+				if strings.EqualFold(allowed, header) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+
+	return func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			origin := ctx.R.Header.Get("Origin")
+			if origin == "" {
+				next(ctx)
+				return
+			}
+			header := ctx.W.Header()
+			preflight := ctx.R.Method == http.MethodOptions && ctx.R.Header.Get("Access-Control-Request-Method") != ""
+			header.Add("Vary", "Origin")
+			if preflight {
+				header.Add("Vary", "Access-Control-Request-Method")
+				header.Add("Vary", "Access-Control-Request-Headers")
+			}
+			if !originAllowed(origin) {
+				if preflight {
+					ctx.W.WriteHeader(http.StatusForbidden)
+					return
+				}
+				next(ctx)
+				return
+			}
+			if allowAllOrigins {
+				header.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				header.Set("Access-Control-Allow-Origin", origin)
+			}
+			if config.AllowCredentials {
+				header.Set("Access-Control-Allow-Credentials", "true")
+			}
+			if !preflight {
+				if exposeHeaders != "" {
+					header.Set("Access-Control-Expose-Headers", exposeHeaders)
+				}
+				next(ctx)
+				return
+			}
+			requestHeaders := ctx.R.Header.Get("Access-Control-Request-Headers")
+			if !methodAllowed(ctx.R.Header.Get("Access-Control-Request-Method")) || !headersAllowed(requestHeaders) {
+				header.Del("Access-Control-Allow-Origin")
+				header.Del("Access-Control-Allow-Credentials")
+				ctx.W.WriteHeader(http.StatusForbidden)
+				return
+			}
+			header.Set("Access-Control-Allow-Methods", allowMethods)
+			if allowAllHeaders {
+				if requestHeaders != "" {
+					header.Set("Access-Control-Allow-Headers", requestHeaders)
+				}
+			} else {
+				header.Set("Access-Control-Allow-Headers", allowHeaders)
+			}
+			if maxAge != "" {
+				header.Set("Access-Control-Max-Age", maxAge)
+			}
+			ctx.W.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+func matchOrigin(pattern, origin string) bool {
+	i := strings.IndexByte(pattern, '*')
+	if i < 0 {
+		return strings.EqualFold(pattern, origin)
+	}
+	prefix, suffix := pattern[:i], pattern[i+1:]
+	return len(origin) >= len(prefix)+len(suffix) &&
+		strings.HasPrefix(strings.ToLower(origin), strings.ToLower(prefix)) &&
+		strings.HasSuffix(strings.ToLower(origin), strings.ToLower(suffix))
+}
@@ -0,0 +1,85 @@
+package msgo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func corsEngine(config CORSConfig) *Engine {
+	engine := New()
+	g := engine.Group("user")
+	g.Use(CORS(config))
+	g.Post("/info", func(ctx *Context) {
+		ctx.String(http.StatusOK, "ok")
+	})
+	return engine
+}
+
+func corsRequest(engine *Engine, method, origin string, headers ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/user/info", nil)
+	r.Header.Set("Origin", origin)
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, r)
+	return w
+}
+
+func TestCORSPreflight(t *testing.T) {
+	engine := corsEngine(CORSConfig{
+		AllowOrigins: []string{"https://*.example.com"},
+		AllowHeaders: []string{"Content-Type"},
+		MaxAge:       time.Hour,
+	})
+	w := corsRequest(engine, http.MethodOptions, "https://app.example.com",
+		"Access-Control-Request-Method", "POST", "Access-Control-Request-Headers", "content-type")
+	header := w.Header()
+	if w.Code != http.StatusNoContent || header.Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		header.Get("Access-Control-Allow-Headers") != "Content-Type" || header.Get("Access-Control-Max-Age") != "3600" {
+		t.Fatalf("preflight = %d %v", w.Code, header)
+	}
+	if w = corsRequest(engine, http.MethodOptions, "https://app.example.com",
+		"Access-Control-Request-Method", "POST", "Access-Control-Request-Headers", "X-Token"); w.Code != http.StatusForbidden {
+		t.Fatalf("preflight with unknown header = %d", w.Code)
+	}
+	if w = corsRequest(engine, http.MethodOptions, "https://app.example.com",
+		"Access-Control-Request-Method", "CONNECT"); w.Code != http.StatusForbidden {
+		t.Fatalf("preflight with unknown method = %d", w.Code)
+	}
+	// 通配只匹配子域名
+	for _, origin := range []string{"https://example.com", "https://evil.com", "http://app.example.com"} {
+		w = corsRequest(engine, http.MethodOptions, origin, "Access-Control-Request-Method", "POST")
+		if w.Code != http.StatusForbidden || w.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Fatalf("preflight from %s = %d", origin, w.Code)
+		}
+	}
+	// 不允许的来源仍然可以访问 只是浏览器读不到响应
+	w = corsRequest(engine, http.MethodPost, "https://evil.com")
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("simple request = %d %v", w.Code, w.Header())
+	}
+}
+
+func TestCORSCredentials(t *testing.T) {
+	engine := corsEngine(CORSConfig{AllowOrigins: []string{"https://app.example.com"}, AllowCredentials: true})
+	w := corsRequest(engine, http.MethodPost, "https://app.example.com")
+	if w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		w.Header().Get("Access-Control-Allow-Credentials") != "true" || w.Header().Get("Vary") != "Origin" {
+		t.Fatalf("credentials = %v", w.Header())
+	}
+
+	engine = corsEngine(CORSConfig{AllowOrigins: []string{"*"}})
+	if w = corsRequest(engine, http.MethodPost, "https://any.com"); w.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Fatalf("allow all = %v", w.Header())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("wildcard origin with credentials did not panic")
+		}
+	}()
+	CORS(CORSConfig{AllowOrigins: []string{"*"}, AllowCredentials: true})
+}
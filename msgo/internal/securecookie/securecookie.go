@@ -0,0 +1,169 @@
+// Package securecookie 提供签名 (HMAC-SHA256) 和加密 (AES-GCM) 的 cookie 编码 支持密钥轮换
+package securecookie
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MinKeySize 每个密钥的最小长度
+const MinKeySize = 32
+
+var (
+	ErrInvalid = errors.New("msgo: invalid cookie")
+	ErrExpired = errors.New("msgo: cookie expired")
+)
+
+type key struct {
+	sign []byte
+	aead cipher.AEAD
+}
+
+// Codec 第一个密钥用于签名和加密 其余的密钥只用于验证和解密
+type Codec struct {
+	keys []key
+}
+
+func New(keys ...[]byte) (*Codec, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("msgo: no cookie keys")
+	}
+	c := &Codec{keys: make([]key, 0, len(keys))}
+	for i, k := range keys {
+		if len(k) < MinKeySize {
+			return nil, fmt.Errorf("msgo: cookie key %d is shorter than %d bytes", i, MinKeySize)
+		}
+		// 从同一个密钥派生出签名和加密两个不同的密钥
+		block, err := aes.NewCipher(derive(k, "msgo cookie encryption"))
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		c.keys = append(c.keys, key{sign: derive(k, "msgo cookie signing"), aead: aead})
+	}
+	return c, nil
+}
+
+func derive(k []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, k)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// ExpiresIn 返回 maxAge 秒之后的时间戳 maxAge 不大于 0 时返回 0 表示不过期
+func ExpiresIn(maxAge int) int64 {
+	if maxAge > 0 {
+		return time.Now().Add(time.Duration(maxAge) * time.Second).Unix()
+	}
+	return 0
+}
+
+func checkExpires(expires int64) error {
+	if expires > 0 && time.Now().Unix() > expires {
+		return ErrExpired
+	}
+	return nil
+}
+
+// Sign 返回 base64(value).expires.base64(mac) 签名包含 cookie 的名字 不能把一个 cookie 的值复制给另一个 cookie
+func (c *Codec) Sign(name string, value []byte, expires int64) string {
+	payload := base64.RawURLEncoding.EncodeToString(value) + "." + strconv.FormatInt(expires, 10)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(sign(c.keys[0].sign, name, payload))
+}
+
+// Verify 任意一个密钥验证通过即可
+func (c *Codec) Verify(name, token string) ([]byte, error) {
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 {
+		return nil, ErrInvalid
+	}
+	payload := token[:i]
+	mac, err := base64.RawURLEncoding.DecodeString(token[i+1:])
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	verified := false
+	for _, k := range c.keys {
+		if hmac.Equal(mac, sign(k.sign, name, payload)) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, ErrInvalid
+	}
+	encoded, expiresStr, ok := strings.Cut(payload, ".")
+	if !ok {
+		return nil, ErrInvalid
+	}
+	expires, err := strconv.ParseInt(expiresStr, 10, 64)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	if err := checkExpires(expires); err != nil {
+		return nil, err
+	}
+	value, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	return value, nil
+}
+
+func sign(k []byte, name, payload string) []byte {
+	mac := hmac.New(sha256.New, k)
+	mac.Write([]byte(name))
+	mac.Write([]byte{0})
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// Encrypt 返回 base64(nonce + 密文) 过期时间和值一起加密 cookie 的名字作为附加数据
+func (c *Codec) Encrypt(name string, value []byte, expires int64) (string, error) {
+	aead := c.keys[0].aead
+	plaintext := make([]byte, 8+len(value))
+	binary.BigEndian.PutUint64(plaintext, uint64(expires))
+	copy(plaintext[8:], value)
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(name))
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt 依次尝试每个密钥
+func (c *Codec) Decrypt(name, token string) ([]byte, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	for _, k := range c.keys {
+		aead := k.aead
+		if len(sealed) < aead.NonceSize()+aead.Overhead()+8 {
+			return nil, ErrInvalid
+		}
+		plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(name))
+		if err != nil {
+			continue
+		}
+		if err := checkExpires(int64(binary.BigEndian.Uint64(plaintext))); err != nil {
+			return nil, err
+		}
+		return plaintext[8:], nil
+	}
+	return nil, ErrInvalid
+}
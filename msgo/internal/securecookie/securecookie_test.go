@@ -0,0 +1,132 @@
+package securecookie_test
+
+import (
+	"bytes"
+	"github.com/demo-go/msgo/internal/securecookie"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	oldKey = bytes.Repeat([]byte("o"), securecookie.MinKeySize)
+	newKey = bytes.Repeat([]byte("n"), securecookie.MinKeySize)
+)
+
+func mustNew(t *testing.T, keys ...[]byte) *securecookie.Codec {
+	t.Helper()
+	c, err := securecookie.New(keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestNew(t *testing.T) {
+	if _, err := securecookie.New(); err == nil {
+		t.Fatal("no keys should be rejected")
+	}
+	if _, err := securecookie.New(newKey, []byte("short")); err == nil {
+		t.Fatal("short key should be rejected")
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	c := mustNew(t, newKey)
+	token := c.Sign("user", []byte("dema"), 0)
+	if value, err := c.Verify("user", token); err != nil || string(value) != "dema" {
+		t.Fatalf("verify = %q %v", value, err)
+	}
+	// 签名包含 cookie 的名字
+	if _, err := c.Verify("admin", token); err != securecookie.ErrInvalid {
+		t.Fatalf("other name err = %v", err)
+	}
+	// 修改值或者过期时间都会导致签名不一致
+	parts := strings.Split(token, ".")
+	for _, tampered := range []string{
+		c.Sign("user", []byte("root"), 0)[:len(parts[0])] + token[len(parts[0]):],
+		parts[0] + ".9999999999." + parts[2],
+		parts[0] + "." + parts[1],
+		"garbage",
+	} {
+		if _, err := c.Verify("user", tampered); err != securecookie.ErrInvalid {
+			t.Fatalf("tampered %q err = %v", tampered, err)
+		}
+	}
+	if _, err := c.Verify("user", c.Sign("user", []byte("dema"), time.Now().Add(-time.Second).Unix())); err != securecookie.ErrExpired {
+		t.Fatalf("expired err = %v", err)
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	c := mustNew(t, newKey)
+	token, err := c.Encrypt("token", []byte("secret"), securecookie.ExpiresIn(60))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(token, "secret") {
+		t.Fatal("value is not encrypted")
+	}
+	// 每次加密使用新的 nonce
+	if again, _ := c.Encrypt("token", []byte("secret"), 0); again == token {
+		t.Fatal("nonce reused")
+	}
+	if value, err := c.Decrypt("token", token); err != nil || string(value) != "secret" {
+		t.Fatalf("decrypt = %q %v", value, err)
+	}
+	// 修改中间的字符 最后一个字符可能只包含不参与解码的填充位
+	flipped := []byte(token)
+	if i := len(flipped) / 2; flipped[i] == 'A' {
+		flipped[i] = 'B'
+	} else {
+		flipped[i] = 'A'
+	}
+	for name, tampered := range map[string]string{"other": token, "token": string(flipped)} {
+		if _, err := c.Decrypt(name, tampered); err != securecookie.ErrInvalid {
+			t.Fatalf("%s err = %v", name, err)
+		}
+	}
+	if _, err := c.Decrypt("token", "short"); err != securecookie.ErrInvalid {
+		t.Fatalf("short err = %v", err)
+	}
+	expired, _ := c.Encrypt("token", []byte("secret"), time.Now().Add(-time.Second).Unix())
+	if _, err := c.Decrypt("token", expired); err != securecookie.ErrExpired {
+		t.Fatalf("expired err = %v", err)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	old := mustNew(t, oldKey)
+	signed := old.Sign("user", []byte("dema"), 0)
+	encrypted, _ := old.Encrypt("token", []byte("secret"), 0)
+
+	// 新密钥放在最前面 旧密钥仍然可以验证和解密
+	rotated := mustNew(t, newKey, oldKey)
+	if value, err := rotated.Verify("user", signed); err != nil || string(value) != "dema" {
+		t.Fatalf("verify = %q %v", value, err)
+	}
+	if value, err := rotated.Decrypt("token", encrypted); err != nil || string(value) != "secret" {
+		t.Fatalf("decrypt = %q %v", value, err)
+	}
+	// 新的值使用新密钥 只有旧密钥时无法验证
+	if _, err := old.Verify("user", rotated.Sign("user", []byte("dema"), 0)); err != securecookie.ErrInvalid {
+		t.Fatalf("verify with old key err = %v", err)
+	}
+	// 移除旧密钥之后 旧的值失效
+	removed := mustNew(t, newKey)
+	if _, err := removed.Verify("user", signed); err != securecookie.ErrInvalid {
+		t.Fatalf("verify err = %v", err)
+	}
+	if _, err := removed.Decrypt("token", encrypted); err != securecookie.ErrInvalid {
+		t.Fatalf("decrypt err = %v", err)
+	}
+}
+
+func TestExpiresIn(t *testing.T) {
+	if securecookie.ExpiresIn(0) != 0 || securecookie.ExpiresIn(-1) != 0 {
+		t.Fatal("non-positive max age should not expire")
+	}
+	if got := securecookie.ExpiresIn(60) - time.Now().Unix(); got < 59 || got > 60 {
+		t.Fatalf("expires in = %d", got)
+	}
+}
@@ -0,0 +1,115 @@
+package tracectx
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+// TraceParent W3C Trace Context 的 traceparent 头
+// 格式 version-traceid-parentid-flags 例如 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+type TraceParent struct {
+	TraceID  [16]byte
+	ParentID [8]byte
+	Flags    byte
+}
+
+const FlagSampled = 0x01
+
+func Parse(header string) (TraceParent, bool) {
+	var tp TraceParent
+	header = strings.TrimSpace(header)
+	parts := strings.Split(header, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return tp, false
+	}
+	// 版本 00 必须正好四段 更高的版本允许后面有扩展字段
+	if parts[0] == "00" && len(parts) != 4 {
+		return tp, false
+	}
+	if !isLowerHex(parts[0]) || !decodeHex(tp.TraceID[:], parts[1]) || !decodeHex(tp.ParentID[:], parts[2]) {
+		return tp, false
+	}
+	var flags [1]byte
+	if !decodeHex(flags[:], parts[3]) {
+		return tp, false
+	}
+	tp.Flags = flags[0]
+	if isZero(tp.TraceID[:]) || isZero(tp.ParentID[:]) {
+		return tp, false
+	}
+	return tp, true
+}
+
+func (tp TraceParent) String() string {
+	return "00-" + hex.EncodeToString(tp.TraceID[:]) + "-" + hex.EncodeToString(tp.ParentID[:]) + "-" + hex.EncodeToString([]byte{tp.Flags})
+}
+
+func (tp TraceParent) Sampled() bool {
+	return tp.Flags&FlagSampled != 0
+}
+
+func NewTraceID() [16]byte {
+	var id [16]byte
+	for isZero(id[:]) {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+func NewSpanID() [8]byte {
+	var id [8]byte
+	for isZero(id[:]) {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+// ValidTraceState 粗略校验 tracestate 最多 32 个 key=value 成员
+func ValidTraceState(state string) bool {
+	if state == "" {
+		return true
+	}
+	members := strings.Split(state, ",")
+	if len(members) > 32 || len(state) > 512 {
+		return false
+	}
+	for _, member := range members {
+		member = strings.TrimSpace(member)
+		if member == "" {
+			continue
+		}
+		i := strings.IndexByte(member, '=')
+		if i <= 0 || i == len(member)-1 {
+			return false
+		}
+	}
+	return true
+}
+
+func decodeHex(dst []byte, s string) bool {
+	if len(s) != len(dst)*2 || !isLowerHex(s) {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func isZero(b []byte) bool {
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
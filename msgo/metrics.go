@@ -0,0 +1,79 @@
+package msgo
+
+import (
+	"github.com/demo-go/msgo/metrics"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type MetricsConfig struct {
+	// Registry 默认 metrics.DefaultRegistry
+	Registry *metrics.Registry
+	// Namespace 指标名前缀 默认 msgo
+	Namespace string
+	// Buckets 请求耗时直方图的桶 单位秒 默认 metrics.DefBuckets
+	Buckets []float64
+	// SizeBuckets 响应大小直方图的桶 单位字节 默认 100B 到 100MB
+	SizeBuckets []float64
+	// ExcludedPaths 按前缀匹配 这些路径的请求不统计 例如指标接口本身
+	ExcludedPaths []string
+}
+
+// Metrics 按路由统计请求数 耗时 正在处理的请求数和响应大小
+// 路由使用注册时的路径 例如 /user/get/:id 避免每个不同的 id 产生一组新的指标
+func Metrics(config MetricsConfig) MiddlewareFunc {
+	if config.Registry == nil {
+		config.Registry = metrics.DefaultRegistry
+	}
+	if config.Namespace == "" {
+		config.Namespace = "msgo"
+	}
+	if config.SizeBuckets == nil {
+		config.SizeBuckets = metrics.ExponentialBuckets(100, 10, 7)
+	}
+	prefix := config.Namespace + "_http_"
+	requests := config.Registry.NewCounterVec(prefix+"requests_total",
+		"Total number of HTTP requests.", "method", "route", "status")
+	duration := config.Registry.NewHistogramVec(prefix+"request_duration_seconds",
+		"HTTP request latency in seconds.", config.Buckets, "method", "route")
+	inFlight := config.Registry.NewGaugeVec(prefix+"requests_in_flight",
+		"Number of HTTP requests being served.", "method", "route")
+	responseSize := config.Registry.NewHistogramVec(prefix+"response_size_bytes",
+		"HTTP response body size in bytes.", config.SizeBuckets, "method", "route")
+
+	return func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			for _, excluded := range config.ExcludedPaths {
+				if strings.HasPrefix(ctx.R.URL.Path, excluded) {
+					next(ctx)
+					return
+				}
+			}
+			method, route := ctx.R.Method, ctx.FullPath()
+			gauge := inFlight.WithLabelValues(method, route)
+			gauge.Inc()
+			defer gauge.Dec()
+
+			start := time.Now()
+			w := newResponseWriter(ctx.W)
+			ctx.W = w
+			defer func() {
+				ctx.W = w.ResponseWriter
+			}()
+			next(ctx)
+
+			requests.WithLabelValues(method, route, strconv.Itoa(w.Status())).Inc()
+			duration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+			responseSize.WithLabelValues(method, route).Observe(float64(w.Size()))
+		}
+	}
+}
+
+// MetricsHandler 以 Prometheus 文本格式输出 registry 中的指标 registry 为空时使用 metrics.DefaultRegistry
+func MetricsHandler(registry *metrics.Registry) HandleFunc {
+	handler := metrics.Handler(registry)
+	return func(ctx *Context) {
+		handler.ServeHTTP(ctx.W, ctx.R)
+	}
+}
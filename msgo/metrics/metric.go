@@ -0,0 +1,297 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefBuckets 默认的延迟直方图 单位秒
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// ExponentialBuckets 返回 count 个桶 第一个上限为 start 之后每个是前一个的 factor 倍
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	if count < 1 || start <= 0 || factor <= 1 {
+		panic("metrics: ExponentialBuckets needs count >= 1, start > 0 and factor > 1")
+	}
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start
+		start *= factor
+	}
+	return buckets
+}
+
+type metric interface {
+	write(w *bufio.Writer, name, labels string)
+}
+
+type child struct {
+	labelValues []string
+	metric      metric
+}
+
+type vec struct {
+	name       string
+	help       string
+	typ        string
+	labelNames []string
+	newMetric  func() metric
+
+	mu       sync.RWMutex
+	children map[string]*child
+}
+
+func (v *vec) with(labelValues []string) metric {
+	if len(labelValues) != len(v.labelNames) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.name, len(v.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	v.mu.RLock()
+	c, ok := v.children[key]
+	v.mu.RUnlock()
+	if ok {
+		return c.metric
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if c, ok = v.children[key]; !ok {
+		c = &child{labelValues: append([]string{}, labelValues...), metric: v.newMetric()}
+		v.children[key] = c
+	}
+	return c.metric
+}
+
+func (v *vec) write(w *bufio.Writer) {
+	v.mu.RLock()
+	children := make([]*child, 0, len(v.children))
+	for _, c := range v.children {
+		children = append(children, c)
+	}
+	v.mu.RUnlock()
+	sort.Slice(children, func(i, j int) bool {
+		a, b := children[i].labelValues, children[j].labelValues
+		for k := range a {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return false
+	})
+	if v.help != "" {
+		fmt.Fprintf(w, "# HELP %s %s\n", v.name, escapeHelp(v.help))
+	}
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.name, v.typ)
+	for _, c := range children {
+		c.metric.write(w, v.name, formatLabels(v.labelNames, c.labelValues))
+	}
+}
+
+// formatLabels 返回不带花括号的 a="1",b="2"
+func formatLabels(names, values []string) string {
+	var b strings.Builder
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabelValue(values[i]))
+		b.WriteByte('"')
+	}
+	return b.String()
+}
+
+func writeSample(w *bufio.Writer, name, labels string, value float64) {
+	w.WriteString(name)
+	if labels != "" {
+		w.WriteByte('{')
+		w.WriteString(labels)
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelValueEscaper.Replace(s)
+}
+
+// atomicFloat 用 CAS 实现 float64 的原子加法
+type atomicFloat struct {
+	bits uint64
+}
+
+func (f *atomicFloat) add(delta float64) {
+	for {
+		old := atomic.LoadUint64(&f.bits)
+		next := math.Float64bits(math.Float64frombits(old) + delta)
+		if atomic.CompareAndSwapUint64(&f.bits, old, next) {
+			return
+		}
+	}
+}
+
+func (f *atomicFloat) set(v float64) {
+	atomic.StoreUint64(&f.bits, math.Float64bits(v))
+}
+
+func (f *atomicFloat) load() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&f.bits))
+}
+
+// Counter 只增不减的计数器
+type Counter struct {
+	value atomicFloat
+}
+
+func (c *Counter) Inc() {
+	c.value.add(1)
+}
+
+// Add v 不能为负数
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		panic("metrics: counter can not decrease")
+	}
+	c.value.add(v)
+}
+
+func (c *Counter) Value() float64 {
+	return c.value.load()
+}
+
+func (c *Counter) write(w *bufio.Writer, name, labels string) {
+	writeSample(w, name, labels, c.Value())
+}
+
+type CounterVec struct {
+	vec *vec
+}
+
+// WithLabelValues 按注册时标签的顺序传入标签值
+func (v *CounterVec) WithLabelValues(values ...string) *Counter {
+	return v.vec.with(values).(*Counter)
+}
+
+// Gauge 可增可减的瞬时值 例如正在处理的请求数
+type Gauge struct {
+	value atomicFloat
+}
+
+func (g *Gauge) Set(v float64) {
+	g.value.set(v)
+}
+
+func (g *Gauge) Inc() {
+	g.value.add(1)
+}
+
+func (g *Gauge) Dec() {
+	g.value.add(-1)
+}
+
+func (g *Gauge) Add(v float64) {
+	g.value.add(v)
+}
+
+func (g *Gauge) Value() float64 {
+	return g.value.load()
+}
+
+func (g *Gauge) write(w *bufio.Writer, name, labels string) {
+	writeSample(w, name, labels, g.Value())
+}
+
+type GaugeVec struct {
+	vec *vec
+}
+
+func (v *GaugeVec) WithLabelValues(values ...string) *Gauge {
+	return v.vec.with(values).(*Gauge)
+}
+
+// Histogram 统计观测值落在每个桶中的次数 以及总和和总次数
+type Histogram struct {
+	upperBounds []float64
+
+	mu     sync.Mutex
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newHistogram(upperBounds []float64) *Histogram {
+	return &Histogram{
+		upperBounds: upperBounds,
+		counts:      make([]uint64, len(upperBounds)),
+	}
+}
+
+func (h *Histogram) Observe(v float64) {
+	// 找到第一个上限 >= v 的桶 都不满足时只计入 +Inf
+	i := sort.SearchFloat64s(h.upperBounds, v)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.sum += v
+	h.count++
+}
+
+func (h *Histogram) write(w *bufio.Writer, name, labels string) {
+	h.mu.Lock()
+	counts := append([]uint64{}, h.counts...)
+	sum, count := h.sum, h.count
+	h.mu.Unlock()
+
+	prefix := labels
+	if prefix != "" {
+		prefix += ","
+	}
+	// Prometheus 的桶是累计的
+	var cumulative uint64
+	for i, upperBound := range h.upperBounds {
+		cumulative += counts[i]
+		writeSample(w, name+"_bucket", prefix+`le="`+formatFloat(upperBound)+`"`, float64(cumulative))
+	}
+	writeSample(w, name+"_bucket", prefix+`le="+Inf"`, float64(count))
+	writeSample(w, name+"_sum", labels, sum)
+	writeSample(w, name+"_count", labels, float64(count))
+}
+
+type HistogramVec struct {
+	vec *vec
+}
+
+func (v *HistogramVec) WithLabelValues(values ...string) *Histogram {
+	return v.vec.with(values).(*Histogram)
+}
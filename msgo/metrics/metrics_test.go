@@ -0,0 +1,90 @@
+package metrics_test
+
+import (
+	"bytes"
+	"github.com/demo-go/msgo"
+	"github.com/demo-go/msgo/metrics"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExposition(t *testing.T) {
+	registry := metrics.NewRegistry()
+	counter := registry.NewCounterVec("jobs_total", "Jobs done.\nSecond line.", "queue")
+	counter.WithLabelValues(`say "hi"`).Add(2)
+	counter.WithLabelValues("a").Inc()
+	registry.NewGaugeVec("temperature", "").WithLabelValues().Set(-1.5)
+	histogram := registry.NewHistogramVec("latency_seconds", "Latency.", []float64{0.1, 1})
+	histogram.WithLabelValues().Observe(0.05)
+	histogram.WithLabelValues().Observe(0.5)
+	histogram.WithLabelValues().Observe(3)
+
+	var buf bytes.Buffer
+	if _, err := registry.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP jobs_total Jobs done.\nSecond line.
+# TYPE jobs_total counter
+jobs_total{queue="a"} 1
+jobs_total{queue="say \"hi\""} 2
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 1
+latency_seconds_bucket{le="1"} 2
+latency_seconds_bucket{le="+Inf"} 3
+latency_seconds_sum 3.55
+latency_seconds_count 3
+# TYPE temperature gauge
+temperature -1.5
+`
+	if buf.String() != want {
+		t.Fatalf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	// 相同的定义重复注册返回同一个指标
+	registry.NewCounterVec("jobs_total", "Jobs done.", "queue").WithLabelValues("a").Inc()
+	if v := counter.WithLabelValues("a").Value(); v != 2 {
+		t.Fatalf("counter = %v", v)
+	}
+}
+
+func TestMiddlewareUsesRoutePattern(t *testing.T) {
+	registry := metrics.NewRegistry()
+	engine := msgo.New()
+	g := engine.Group("user")
+	g.Use(msgo.Metrics(msgo.MetricsConfig{Registry: registry, ExcludedPaths: []string{"/user/metrics"}}))
+	g.Get("/get/:id", func(ctx *msgo.Context) {
+		_ = ctx.String(http.StatusOK, "user %s", ctx.FullPath())
+	})
+	g.Get("/metrics", msgo.MetricsHandler(registry))
+
+	for _, id := range []string{"1", "2", "3"} {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/user/get/"+id, nil))
+		if body := w.Body.String(); body != "user /user/get/:id" {
+			t.Fatalf("body = %q", body)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/user/metrics", nil))
+	if w.Header().Get("Content-Type") != metrics.ContentType {
+		t.Fatalf("content type = %q", w.Header().Get("Content-Type"))
+	}
+	body := w.Body.String()
+	for _, line := range []string{
+		`msgo_http_requests_total{method="GET",route="/user/get/:id",status="200"} 3`,
+		`msgo_http_requests_in_flight{method="GET",route="/user/get/:id"} 0`,
+		`msgo_http_request_duration_seconds_count{method="GET",route="/user/get/:id"} 3`,
+		`msgo_http_response_size_bytes_sum{method="GET",route="/user/get/:id"} 54`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Fatalf("missing %q in\n%s", line, body)
+		}
+	}
+	if strings.Contains(body, "/user/metrics") {
+		t.Fatalf("excluded path recorded:\n%s", body)
+	}
+}
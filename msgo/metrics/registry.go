@@ -0,0 +1,156 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// ContentType Prometheus 文本格式 0.0.4
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var (
+	metricNameRE = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNameRE  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// DefaultRegistry 没有指定 Registry 时使用
+var DefaultRegistry = NewRegistry()
+
+// Registry 保存所有的指标 按 Prometheus 文本格式输出
+type Registry struct {
+	mu   sync.RWMutex
+	vecs map[string]*vec
+}
+
+func NewRegistry() *Registry {
+	return &Registry{vecs: make(map[string]*vec)}
+}
+
+func (r *Registry) NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	v := r.register(name, help, "counter", labelNames, func() metric {
+		return &Counter{}
+	})
+	return &CounterVec{vec: v}
+}
+
+func (r *Registry) NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	v := r.register(name, help, "gauge", labelNames, func() metric {
+		return &Gauge{}
+	})
+	return &GaugeVec{vec: v}
+}
+
+// NewHistogramVec buckets 为每个桶的上限 会自动加上 +Inf 为空时使用 DefBuckets
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i] <= buckets[i-1] {
+			panic(fmt.Sprintf("metrics: histogram %s buckets must be in increasing order", name))
+		}
+	}
+	for _, label := range labelNames {
+		if label == "le" {
+			panic(fmt.Sprintf("metrics: histogram %s can not use label le", name))
+		}
+	}
+	upperBounds := append([]float64{}, buckets...)
+	v := r.register(name, help, "histogram", labelNames, func() metric {
+		return newHistogram(upperBounds)
+	})
+	return &HistogramVec{vec: v}
+}
+
+// register 同名同类型同标签的指标只注册一次 重复注册返回已有的指标 否则 panic
+func (r *Registry) register(name, help, typ string, labelNames []string, newMetric func() metric) *vec {
+	if !metricNameRE.MatchString(name) {
+		panic(fmt.Sprintf("metrics: invalid metric name %q", name))
+	}
+	for _, label := range labelNames {
+		if !labelNameRE.MatchString(label) {
+			panic(fmt.Sprintf("metrics: invalid label name %q", label))
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing, ok := r.vecs[name]; ok {
+		if existing.typ != typ || !equalStrings(existing.labelNames, labelNames) {
+			panic(fmt.Sprintf("metrics: %s already registered with different type or labels", name))
+		}
+		return existing
+	}
+	v := &vec{
+		name:       name,
+		help:       help,
+		typ:        typ,
+		labelNames: append([]string{}, labelNames...),
+		children:   make(map[string]*child),
+		newMetric:  newMetric,
+	}
+	r.vecs[name] = v
+	return v
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteTo 按指标名排序输出 同一个指标的不同标签按标签值排序 保证输出稳定
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.RLock()
+	vecs := make([]*vec, 0, len(r.vecs))
+	for _, v := range r.vecs {
+		vecs = append(vecs, v)
+	}
+	r.mu.RUnlock()
+	sort.Slice(vecs, func(i, j int) bool {
+		return vecs[i].name < vecs[j].name
+	})
+	cw := &countWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, v := range vecs {
+		v.write(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Handler 以 Prometheus 文本格式输出 registry 中的指标 registry 为空时使用 DefaultRegistry
+func Handler(registry *Registry) http.Handler {
+	if registry == nil {
+		registry = DefaultRegistry
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		w.Header().Set("Cache-Control", "no-store")
+		if r.Method == http.MethodHead {
+			return
+		}
+		_, _ = registry.WriteTo(w)
+	})
+}
@@ -0,0 +1,39 @@
+package msgo
+
+import "os"
+
+// EnvMsgoMode 通过环境变量设置运行模式 例如开发时 MSGO_MODE=debug
+const EnvMsgoMode = "MSGO_MODE"
+
+const (
+	// DebugMode 模板文件修改后自动重新解析 每次渲染都会检查所有模板文件的修改时间 只在开发时使用
+	DebugMode = "debug"
+	// ReleaseMode 模板只解析一次 之后使用缓存
+	ReleaseMode = "release"
+)
+
+// msgoMode 默认 release 避免生产环境每次渲染都读取模板文件的信息
+var msgoMode = ReleaseMode
+
+func init() {
+	if mode := os.Getenv(EnvMsgoMode); mode != "" {
+		SetMode(mode)
+	}
+}
+
+func SetMode(value string) {
+	switch value {
+	case DebugMode, ReleaseMode:
+		msgoMode = value
+	default:
+		panic("msgo mode unknown: " + value + " (available mode: debug release)")
+	}
+}
+
+func Mode() string {
+	return msgoMode
+}
+
+func IsDebugging() bool {
+	return msgoMode == DebugMode
+}
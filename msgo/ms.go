@@ -2,11 +2,20 @@ package msgo
 
 import (
 	"fmt"
+	"github.com/demo-go/msgo/internal/securecookie"
 	"github.com/demo-go/msgo/render"
+	"github.com/demo-go/msgo/tracing"
+	"github.com/demo-go/msgo/websocket"
 	"html/template"
+	"io/fs"
 	"log"
+	"net"
 	"net/http"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 type HandleFunc func(ctx *Context)
@@ -27,22 +36,39 @@ func (r *routerGroup) Use(middlewareFunc ...MiddlewareFunc) {
 }
 
 func (r *routerGroup) methodHandle(name string, method string, h HandleFunc, ctx *Context) {
+	// 设置了 Tracer 时 handler 和每个中间件都会记录一个 span
+	var tracer *tracing.Tracer
+	if ctx.engine != nil {
+		tracer = ctx.engine.Tracer
+	}
+	if tracer != nil {
+		h = traceHandle(tracer, "handler", h)
+	}
 	// 通用中间件
 	if r.middlewares != nil {
 		for _, middlewareFunc := range r.middlewares {
-			h = middlewareFunc(h)
+			h = traceMiddleware(tracer, middlewareFunc, h)
 		}
 	}
 	// 路由级别中间件
 	middlewareFuncs := r.middlewaresFuncMap[name][method]
 	if middlewareFuncs != nil {
 		for _, middlewareFunc := range middlewareFuncs {
-			h = middlewareFunc(h)
+			h = traceMiddleware(tracer, middlewareFunc, h)
 		}
 	}
+	if tracer != nil {
+		h = traceServer(tracer, h)
+	}
 	h(ctx)
 }
 
+func (r *routerGroup) allowedMethods(name string) string {
+	methods := append([]string{}, r.handleMethodMap[name]...)
+	methods = append(methods, http.MethodOptions)
+	return strings.Join(methods, ", ")
+}
+
 func (r *routerGroup) handle(name string, method string, handleFunc HandleFunc, middlewareFunc ...MiddlewareFunc) {
 	_, ok := r.handleFuncMap[name]
 	if !ok {
@@ -54,6 +80,7 @@ func (r *routerGroup) handle(name string, method string, handleFunc HandleFunc,
 		panic("有重复的路由")
 	}
 	r.handleFuncMap[name][method] = handleFunc
+	r.handleMethodMap[name] = append(r.handleMethodMap[name], method)
 	r.middlewaresFuncMap[name][method] = append(r.middlewaresFuncMap[name][method], middlewareFunc...)
 	r.treeNode.Put(name)
 }
@@ -108,9 +135,16 @@ func (r *router) Group(name string) *routerGroup {
 
 type Engine struct {
 	router
-	funcMap    template.FuncMap
-	HTMLRender render.HTMLRender
-	pool       sync.Pool
+	funcMap           template.FuncMap
+	HTMLRender        render.HTMLRender
+	WebSocketUpgrader *websocket.Upgrader
+	Tracer            *tracing.Tracer
+	CookieOptions     *CookieOptions
+	trustedProxies    []*net.IPNet
+	cookieCodec       *securecookie.Codec
+	pool              sync.Pool
+	templateCache     sync.Map
+	templateCacheSize int64
 }
 
 func New() *Engine {
@@ -129,24 +163,146 @@ func (e *Engine) allocateContext() any {
 
 func (e *Engine) SetFuncMap(funcMap template.FuncMap) {
 	e.funcMap = funcMap
+	if m, ok := e.HTMLRender.(*render.MultiTemplate); ok {
+		m.FuncMap = funcMap
+	}
 }
 
 func (e *Engine) LoadTemplate(pattern string) {
+	if IsDebugging() {
+		e.loadTemplateDebug(nil, pattern)
+		return
+	}
 	t := template.Must(template.New("").Funcs(e.funcMap).ParseGlob(pattern))
 	e.SetHtmlTemplate(t)
 }
 
+// LoadTemplateFS 从 fsys 中加载模板 可以配合 embed.FS 把模板打包进二进制文件
+func (e *Engine) LoadTemplateFS(fsys fs.FS, patterns ...string) {
+	if IsDebugging() {
+		e.loadTemplateDebug(fsys, patterns...)
+		return
+	}
+	t := template.Must(template.New("").Funcs(e.funcMap).ParseFS(fsys, patterns...))
+	e.SetHtmlTemplate(t)
+}
+
+func (e *Engine) loadTemplateDebug(fsys fs.FS, patterns ...string) {
+	set := &render.TemplateSet{
+		FS:       fsys,
+		Patterns: patterns,
+		FuncMap:  e.funcMap,
+		Reload:   true,
+	}
+	if _, err := set.Template(); err != nil {
+		panic(err)
+	}
+	e.HTMLRender = &render.HTMLDebug{Set: set}
+}
+
 func (e *Engine) SetHtmlTemplate(t *template.Template) {
-	e.HTMLRender = render.HTMLRender{
+	e.HTMLRender = &render.HTMLProduction{
 		Template: t,
 	}
 }
 
+// LoadLayoutTemplate 为 pages 匹配到的每个页面构建一个模板集合 包含布局和 includes 匹配到的公共片段
+func (e *Engine) LoadLayoutTemplate(layout string, pages string, includes ...string) {
+	e.LoadLayoutTemplateFS(nil, layout, pages, includes...)
+}
+
+// LoadLayoutTemplateFS 和 LoadLayoutTemplate 相同 但模板从 fsys 中读取
+func (e *Engine) LoadLayoutTemplateFS(fsys fs.FS, layout string, pages string, includes ...string) {
+	pageFiles := mustGlob(fsys, pages)
+	var includeFiles []string
+	for _, pattern := range includes {
+		includeFiles = append(includeFiles, mustGlob(fsys, pattern)...)
+	}
+	if err := e.multiTemplate().AddLayoutFS(fsys, layout, pageFiles, includeFiles...); err != nil {
+		panic(err)
+	}
+}
+
+// AddTemplate 用若干文件组成名为 page 的模板集合 渲染时执行第一个文件
+func (e *Engine) AddTemplate(page string, files ...string) {
+	if err := e.multiTemplate().AddFromFiles(page, files...); err != nil {
+		panic(err)
+	}
+}
+
+func (e *Engine) multiTemplate() *render.MultiTemplate {
+	m, ok := e.HTMLRender.(*render.MultiTemplate)
+	if !ok {
+		m = render.NewMultiTemplate(e.funcMap, IsDebugging())
+		e.HTMLRender = m
+	}
+	return m
+}
+
+// maxTemplateCacheSize 模板名或者文件名来自请求时 缓存不能无限增长 超过后不再缓存新的模板
+const maxTemplateCacheSize = 1000
+
+type templateCacheKey struct {
+	fsys fs.FS
+	key  string
+}
+
+// templateSet 返回 HTMLTemplate HTMLTemplateGlob 和 HTMLTemplateFS 使用的模板缓存
+func (e *Engine) templateSet(fsys fs.FS, name string, files []string, patterns []string) *render.TemplateSet {
+	set := &render.TemplateSet{
+		Name:     name,
+		FS:       fsys,
+		Files:    files,
+		Patterns: patterns,
+		FuncMap:  e.funcMap,
+		Reload:   IsDebugging(),
+	}
+	// 不可比较的 fs.FS 不能作为 map 的 key 不做缓存
+	if fsys != nil && !reflect.TypeOf(fsys).Comparable() {
+		return set
+	}
+	key := templateCacheKey{
+		fsys: fsys,
+		key:  name + "\x00" + strings.Join(files, "\x00") + "\x00" + strings.Join(patterns, "\x00"),
+	}
+	if cached, ok := e.templateCache.Load(key); ok {
+		return cached.(*render.TemplateSet)
+	}
+	if atomic.LoadInt64(&e.templateCacheSize) >= maxTemplateCacheSize {
+		return set
+	}
+	cached, loaded := e.templateCache.LoadOrStore(key, set)
+	if !loaded {
+		atomic.AddInt64(&e.templateCacheSize, 1)
+	}
+	return cached.(*render.TemplateSet)
+}
+
+func mustGlob(fsys fs.FS, pattern string) []string {
+	var files []string
+	var err error
+	if fsys != nil {
+		files, err = fs.Glob(fsys, pattern)
+	} else {
+		files, err = filepath.Glob(pattern)
+	}
+	if err != nil {
+		panic(err)
+	}
+	if len(files) == 0 {
+		panic(fmt.Sprintf("msgo: pattern matches no files: %#q", pattern))
+	}
+	return files
+}
+
 func (e *Engine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx := e.pool.Get().(*Context)
-	ctx.W = w
-	ctx.R = r
+	ctx.reset(w, r)
 	e.httpRequestHandle(ctx, w, r)
+	// 中间件通过 WithContext 替换了 ctx.R 时 net/http 只会清理原始请求的 multipart 临时文件
+	if form := ctx.R.MultipartForm; ctx.R != r && form != nil && form != r.MultipartForm {
+		_ = form.RemoveAll()
+	}
 	e.pool.Put(ctx)
 }
 
@@ -157,6 +313,7 @@ func (e *Engine) httpRequestHandle(ctx *Context, w http.ResponseWriter, r *http.
 		node := group.treeNode.Get(routerName)
 		// 路由成功匹配
 		if node != nil && node.isEnd {
+			ctx.fullPath = "/" + group.name + node.routerName
 			handle, ok := group.handleFuncMap[node.routerName]["ANY"]
 			if ok {
 				group.methodHandle(node.routerName, "ANY", handle, ctx)
@@ -167,6 +324,17 @@ func (e *Engine) httpRequestHandle(ctx *Context, w http.ResponseWriter, r *http.
 				group.methodHandle(node.routerName, method, handle, ctx)
 				return
 			}
+			allow := group.allowedMethods(node.routerName)
+			// 没有注册 OPTIONS 时自动应答 仍然经过分组中间件 方便 CORS 处理预检请求
+			// 不会经过其他方法的路由级别中间件 例如鉴权中间件不应该拒绝预检请求
+			if method == http.MethodOptions {
+				group.methodHandle(node.routerName, method, func(ctx *Context) {
+					ctx.W.Header().Set("Allow", allow)
+					ctx.W.WriteHeader(http.StatusNoContent)
+				}, ctx)
+				return
+			}
+			w.Header().Set("Allow", allow)
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			fmt.Fprintf(w, "%s %s not allowed \n", r.RequestURI, method)
 			return
@@ -0,0 +1,156 @@
+package msgo
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+)
+
+// ErrTooManyParts multipart 请求中的 part 数量超过了 MultipartConfig.MaxParts
+var ErrTooManyParts = errors.New("msgo: too many multipart parts")
+
+const (
+	defaultMaxParts     = 1000
+	defaultMaxFieldSize = 1 << 20
+)
+
+type MultipartConfig struct {
+	// MaxFileSize 单个文件 part 的最大字节数 默认使用 Upload 中间件的 MaxFileSize 都没有设置时不限制
+	MaxFileSize int64
+	// MaxFieldSize 普通表单字段的最大字节数 默认 1MB
+	MaxFieldSize int64
+	// MaxParts part 的最大数量 默认 1000
+	MaxParts int
+	// AllowedTypes 允许的文件类型 根据 part 的前 512 个字节检测 默认使用 Upload 中间件的 AllowedTypes
+	AllowedTypes []string
+	// Progress 每次从 part 读取数据后调用 read 为这个 part 已经读取的字节数
+	Progress func(part *MultipartPart, read int64)
+}
+
+// MultipartPart multipart 请求中的一个 part 读取时检查大小限制并报告进度
+type MultipartPart struct {
+	*multipart.Part
+	r           *bufio.Reader
+	body        io.Reader
+	limit       int64
+	read        int64
+	contentType string
+	progress    func(part *MultipartPart, read int64)
+}
+
+// IsFile part 是否为文件
+func (p *MultipartPart) IsFile() bool {
+	return p.FileName() != ""
+}
+
+// ContentType 根据内容检测的文件类型 不信任客户端传来的 Content-Type
+func (p *MultipartPart) ContentType() string {
+	return p.contentType
+}
+
+// Size 已经读取的字节数
+func (p *MultipartPart) Size() int64 {
+	return p.read
+}
+
+func (p *MultipartPart) Read(b []byte) (int, error) {
+	// 多读一个字节 判断是否超过限制
+	if p.limit > 0 && int64(len(b)) > p.limit-p.read+1 {
+		b = b[:p.limit-p.read+1]
+	}
+	n, err := p.r.Read(b)
+	err = bodyError(err, p.body)
+	if p.limit > 0 && p.read+int64(n) > p.limit {
+		n = int(p.limit - p.read)
+		err = p.tooLarge()
+	}
+	p.read += int64(n)
+	if n > 0 && p.progress != nil {
+		p.progress(p, p.read)
+	}
+	return n, err
+}
+
+func (p *MultipartPart) tooLarge() error {
+	if p.IsFile() {
+		return fmt.Errorf("%w: %s is larger than %d bytes", ErrFileTooLarge, SanitizeFilename(p.FileName()), p.limit)
+	}
+	return fmt.Errorf("%w: field %s is larger than %d bytes", ErrBodyTooLarge, p.FormName(), p.limit)
+}
+
+// MultipartReader 返回请求体的 multipart.Reader 不会缓存请求体
+// 不能和 MultipartForm FormFile 等方法同时使用
+func (c *Context) MultipartReader() (*multipart.Reader, error) {
+	return c.R.MultipartReader()
+}
+
+// StreamMultipart 按照到达的顺序把每个 part 交给 fn 处理 不会把上传的文件缓存到内存或者临时文件
+// fn 没有读完的数据会被丢弃 fn 返回错误时停止处理并返回这个错误
+// 超过限制时返回 ErrFileTooLarge ErrBodyTooLarge ErrFileType 或 ErrTooManyParts
+func (c *Context) StreamMultipart(config MultipartConfig, fn func(part *MultipartPart) error) error {
+	if c.upload != nil {
+		if config.MaxFileSize <= 0 {
+			config.MaxFileSize = c.upload.MaxFileSize
+		}
+		if config.AllowedTypes == nil {
+			config.AllowedTypes = c.upload.AllowedTypes
+		}
+	}
+	if config.MaxFieldSize <= 0 {
+		config.MaxFieldSize = defaultMaxFieldSize
+	}
+	if config.MaxParts <= 0 {
+		config.MaxParts = defaultMaxParts
+	}
+	mr, err := c.MultipartReader()
+	if err != nil {
+		return err
+	}
+	for count := 0; ; count++ {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return bodyError(err, c.R.Body)
+		}
+		if count >= config.MaxParts {
+			p.Close()
+			return ErrTooManyParts
+		}
+		part := &MultipartPart{
+			Part:     p,
+			r:        bufio.NewReaderSize(p, 512),
+			body:     c.R.Body,
+			limit:    config.MaxFieldSize,
+			progress: config.Progress,
+		}
+		if part.IsFile() {
+			part.limit = config.MaxFileSize
+			// Peek 不会计入已读取的字节数
+			head, err := part.r.Peek(512)
+			if err != nil && err != io.EOF {
+				p.Close()
+				return bodyError(err, c.R.Body)
+			}
+			part.contentType = detectContentType(head)
+			if !typeAllowed(config.AllowedTypes, part.contentType) {
+				p.Close()
+				return fmt.Errorf("%w: %s is %s", ErrFileType, SanitizeFilename(part.FileName()), part.contentType)
+			}
+		}
+		err = fn(part)
+		p.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// SavePartTo 把文件 part 的剩余内容保存到 dir 目录中 返回保存的路径
+// 文件名的处理和 SaveUploadFileTo 相同 超过大小限制时不会留下文件
+func (c *Context) SavePartTo(part *MultipartPart, dir string) (string, error) {
+	return saveUnique(part, dir, part.FileName())
+}
@@ -0,0 +1,123 @@
+package msgo
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func streamRequest(t *testing.T, fields map[string]string, filename string, content []byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for k, v := range fields {
+		mw.WriteField(k, v)
+	}
+	part, err := mw.CreateFormFile("video", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(content)
+	mw.Close()
+	r := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func TestStreamMultipart(t *testing.T) {
+	dir := t.TempDir()
+	content := append(append([]byte{}, pngHeader...), bytes.Repeat([]byte{1}, 10000)...)
+	ctx := &Context{R: streamRequest(t, map[string]string{"title": "holiday"}, "../a.png", content)}
+
+	var title, saved string
+	var progress []int64
+	err := ctx.StreamMultipart(MultipartConfig{
+		MaxFileSize:  20000,
+		AllowedTypes: []string{"image/png"},
+		Progress: func(part *MultipartPart, read int64) {
+			if part.IsFile() {
+				progress = append(progress, read)
+			}
+		},
+	}, func(part *MultipartPart) error {
+		if !part.IsFile() {
+			data, err := io.ReadAll(part)
+			title = string(data)
+			return err
+		}
+		if part.ContentType() != "image/png" {
+			t.Errorf("content type = %s", part.ContentType())
+		}
+		var err error
+		saved, err = ctx.SavePartTo(part, dir)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if title != "holiday" {
+		t.Fatalf("title = %q", title)
+	}
+	if saved != filepath.Join(dir, "a.png") {
+		t.Fatalf("saved = %s", saved)
+	}
+	if data, _ := os.ReadFile(saved); !bytes.Equal(data, content) {
+		t.Fatal("saved content mismatch")
+	}
+	if len(progress) == 0 || progress[len(progress)-1] != int64(len(content)) {
+		t.Fatalf("progress = %v", progress)
+	}
+}
+
+func TestStreamMultipartLimits(t *testing.T) {
+	dir := t.TempDir()
+	content := append(append([]byte{}, pngHeader...), bytes.Repeat([]byte{1}, 10000)...)
+	save := func(ctx *Context) func(part *MultipartPart) error {
+		return func(part *MultipartPart) error {
+			if !part.IsFile() {
+				_, err := io.Copy(io.Discard, part)
+				return err
+			}
+			_, err := ctx.SavePartTo(part, dir)
+			return err
+		}
+	}
+
+	ctx := &Context{R: streamRequest(t, nil, "a.png", content)}
+	if err := ctx.StreamMultipart(MultipartConfig{MaxFileSize: 1000}, save(ctx)); !errors.Is(err, ErrFileTooLarge) {
+		t.Fatalf("err = %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("partial file left: %d entries", len(entries))
+	}
+
+	ctx = &Context{R: streamRequest(t, nil, "a.png", []byte("plain text"))}
+	if err := ctx.StreamMultipart(MultipartConfig{AllowedTypes: []string{"image/*"}}, save(ctx)); !errors.Is(err, ErrFileType) {
+		t.Fatalf("err = %v", err)
+	}
+
+	ctx = &Context{R: streamRequest(t, map[string]string{"a": "1", "b": "2"}, "a.png", content)}
+	if err := ctx.StreamMultipart(MultipartConfig{MaxParts: 2}, save(ctx)); !errors.Is(err, ErrTooManyParts) {
+		t.Fatalf("err = %v", err)
+	}
+
+	// Upload 中间件的限制同样适用于流式处理
+	engine := New()
+	var streamErr error
+	engine.Group("user").Post("/stream", func(ctx *Context) {
+		streamErr = ctx.StreamMultipart(MultipartConfig{}, save(ctx))
+	}, Upload(UploadConfig{MaxBodySize: 5000}))
+	r := streamRequest(t, nil, "a.png", content)
+	r.URL.Path = "/user/stream"
+	r.ContentLength = -1
+	engine.ServeHTTP(httptest.NewRecorder(), r)
+	if !errors.Is(streamErr, ErrBodyTooLarge) {
+		t.Fatalf("err = %v", streamErr)
+	}
+}
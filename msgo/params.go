@@ -0,0 +1,311 @@
+package msgo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParamError 查询参数或表单参数无法转换为需要的类型
+type ParamError struct {
+	// Source 为 query 或 form
+	Source string
+	Key    string
+	Value  string
+	Err    error
+}
+
+func (e *ParamError) Error() string {
+	return fmt.Sprintf("msgo: %s parameter %q = %q: %v", e.Source, e.Key, e.Value, e.Err)
+}
+
+func (e *ParamError) Unwrap() error {
+	return e.Err
+}
+
+// 下面的 QueryXxx 和 PostFormXxx 在参数不存在或为空时返回默认值
+// 参数无法转换时返回默认值和 *ParamError
+// 切片版本同时支持 ids=1&ids=2 和 ids=1,2 两种写法 参数不存在时返回 nil
+
+func (c *Context) QueryInt(key string, defaultValue int) (int, error) {
+	return parseInt("query", c.GetQueryArr, key, defaultValue)
+}
+
+func (c *Context) QueryInt64(key string, defaultValue int64) (int64, error) {
+	return parseInt64("query", c.GetQueryArr, key, defaultValue)
+}
+
+func (c *Context) QueryUint(key string, defaultValue uint) (uint, error) {
+	return parseUint("query", c.GetQueryArr, key, defaultValue)
+}
+
+func (c *Context) QueryFloat(key string, defaultValue float64) (float64, error) {
+	return parseFloat("query", c.GetQueryArr, key, defaultValue)
+}
+
+func (c *Context) QueryBool(key string, defaultValue bool) (bool, error) {
+	return parseBoolValue("query", c.GetQueryArr, key, defaultValue)
+}
+
+// QueryTime layout 为空时使用 time.RFC3339
+func (c *Context) QueryTime(key, layout string, defaultValue time.Time) (time.Time, error) {
+	return parseTime("query", c.GetQueryArr, key, layout, defaultValue)
+}
+
+// QueryDuration 格式和 time.ParseDuration 相同 例如 1h30m
+func (c *Context) QueryDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+	return parseDuration("query", c.GetQueryArr, key, defaultValue)
+}
+
+func (c *Context) QueryIntSlice(key string) ([]int, error) {
+	return parseIntSlice("query", c.GetQueryArr, key)
+}
+
+func (c *Context) QueryInt64Slice(key string) ([]int64, error) {
+	return parseInt64Slice("query", c.GetQueryArr, key)
+}
+
+func (c *Context) QueryFloatSlice(key string) ([]float64, error) {
+	return parseFloatSlice("query", c.GetQueryArr, key)
+}
+
+func (c *Context) QueryBoolSlice(key string) ([]bool, error) {
+	return parseBoolSlice("query", c.GetQueryArr, key)
+}
+
+func (c *Context) PostFormInt(key string, defaultValue int) (int, error) {
+	return parseInt("form", c.GetPostFormArr, key, defaultValue)
+}
+
+func (c *Context) PostFormInt64(key string, defaultValue int64) (int64, error) {
+	return parseInt64("form", c.GetPostFormArr, key, defaultValue)
+}
+
+func (c *Context) PostFormUint(key string, defaultValue uint) (uint, error) {
+	return parseUint("form", c.GetPostFormArr, key, defaultValue)
+}
+
+func (c *Context) PostFormFloat(key string, defaultValue float64) (float64, error) {
+	return parseFloat("form", c.GetPostFormArr, key, defaultValue)
+}
+
+// PostFormBool 复选框提交的 on 也会被当作 true
+func (c *Context) PostFormBool(key string, defaultValue bool) (bool, error) {
+	return parseBoolValue("form", c.GetPostFormArr, key, defaultValue)
+}
+
+func (c *Context) PostFormTime(key, layout string, defaultValue time.Time) (time.Time, error) {
+	return parseTime("form", c.GetPostFormArr, key, layout, defaultValue)
+}
+
+func (c *Context) PostFormDuration(key string, defaultValue time.Duration) (time.Duration, error) {
+	return parseDuration("form", c.GetPostFormArr, key, defaultValue)
+}
+
+func (c *Context) PostFormIntSlice(key string) ([]int, error) {
+	return parseIntSlice("form", c.GetPostFormArr, key)
+}
+
+func (c *Context) PostFormInt64Slice(key string) ([]int64, error) {
+	return parseInt64Slice("form", c.GetPostFormArr, key)
+}
+
+func (c *Context) PostFormFloatSlice(key string) ([]float64, error) {
+	return parseFloatSlice("form", c.GetPostFormArr, key)
+}
+
+func (c *Context) PostFormBoolSlice(key string) ([]bool, error) {
+	return parseBoolSlice("form", c.GetPostFormArr, key)
+}
+
+type lookupFunc func(key string) ([]string, bool)
+
+// firstValue 返回第一个值 空字符串当作不存在
+func firstValue(lookup lookupFunc, key string) (string, bool) {
+	values, ok := lookup(key)
+	if !ok || len(values) == 0 {
+		return "", false
+	}
+	value := strings.TrimSpace(values[0])
+	return value, value != ""
+}
+
+// splitValues 展开逗号分隔的值 忽略空值
+func splitValues(lookup lookupFunc, key string) []string {
+	values, ok := lookup(key)
+	if !ok {
+		return nil
+	}
+	var out []string
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				out = append(out, part)
+			}
+		}
+	}
+	return out
+}
+
+func parseInt(source string, lookup lookupFunc, key string, defaultValue int) (int, error) {
+	value, ok := firstValue(lookup, key)
+	if !ok {
+		return defaultValue, nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return defaultValue, &ParamError{Source: source, Key: key, Value: value, Err: err}
+	}
+	return i, nil
+}
+
+func parseInt64(source string, lookup lookupFunc, key string, defaultValue int64) (int64, error) {
+	value, ok := firstValue(lookup, key)
+	if !ok {
+		return defaultValue, nil
+	}
+	i, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return defaultValue, &ParamError{Source: source, Key: key, Value: value, Err: err}
+	}
+	return i, nil
+}
+
+func parseUint(source string, lookup lookupFunc, key string, defaultValue uint) (uint, error) {
+	value, ok := firstValue(lookup, key)
+	if !ok {
+		return defaultValue, nil
+	}
+	u, err := strconv.ParseUint(value, 10, strconv.IntSize)
+	if err != nil {
+		return defaultValue, &ParamError{Source: source, Key: key, Value: value, Err: err}
+	}
+	return uint(u), nil
+}
+
+func parseFloat(source string, lookup lookupFunc, key string, defaultValue float64) (float64, error) {
+	value, ok := firstValue(lookup, key)
+	if !ok {
+		return defaultValue, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return defaultValue, &ParamError{Source: source, Key: key, Value: value, Err: err}
+	}
+	return f, nil
+}
+
+// parseBool 在 strconv.ParseBool 的基础上支持 on off yes no
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "on", "yes":
+		return true, nil
+	case "off", "no":
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+func parseBoolValue(source string, lookup lookupFunc, key string, defaultValue bool) (bool, error) {
+	value, ok := firstValue(lookup, key)
+	if !ok {
+		return defaultValue, nil
+	}
+	b, err := parseBool(value)
+	if err != nil {
+		return defaultValue, &ParamError{Source: source, Key: key, Value: value, Err: err}
+	}
+	return b, nil
+}
+
+func parseTime(source string, lookup lookupFunc, key, layout string, defaultValue time.Time) (time.Time, error) {
+	value, ok := firstValue(lookup, key)
+	if !ok {
+		return defaultValue, nil
+	}
+	if layout == "" {
+		layout = time.RFC3339
+	}
+	t, err := time.Parse(layout, value)
+	if err != nil {
+		return defaultValue, &ParamError{Source: source, Key: key, Value: value, Err: err}
+	}
+	return t, nil
+}
+
+func parseDuration(source string, lookup lookupFunc, key string, defaultValue time.Duration) (time.Duration, error) {
+	value, ok := firstValue(lookup, key)
+	if !ok {
+		return defaultValue, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return defaultValue, &ParamError{Source: source, Key: key, Value: value, Err: err}
+	}
+	return d, nil
+}
+
+func parseIntSlice(source string, lookup lookupFunc, key string) ([]int, error) {
+	values := splitValues(lookup, key)
+	if values == nil {
+		return nil, nil
+	}
+	out := make([]int, len(values))
+	for i, value := range values {
+		v, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, &ParamError{Source: source, Key: key, Value: value, Err: err}
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+func parseInt64Slice(source string, lookup lookupFunc, key string) ([]int64, error) {
+	values := splitValues(lookup, key)
+	if values == nil {
+		return nil, nil
+	}
+	out := make([]int64, len(values))
+	for i, value := range values {
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, &ParamError{Source: source, Key: key, Value: value, Err: err}
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+func parseFloatSlice(source string, lookup lookupFunc, key string) ([]float64, error) {
+	values := splitValues(lookup, key)
+	if values == nil {
+		return nil, nil
+	}
+	out := make([]float64, len(values))
+	for i, value := range values {
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, &ParamError{Source: source, Key: key, Value: value, Err: err}
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+func parseBoolSlice(source string, lookup lookupFunc, key string) ([]bool, error) {
+	values := splitValues(lookup, key)
+	if values == nil {
+		return nil, nil
+	}
+	out := make([]bool, len(values))
+	for i, value := range values {
+		v, err := parseBool(value)
+		if err != nil {
+			return nil, &ParamError{Source: source, Key: key, Value: value, Err: err}
+		}
+		out[i] = v
+	}
+	return out, nil
+}
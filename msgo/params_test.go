@@ -0,0 +1,83 @@
+package msgo
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueryAccessors(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet,
+		"/user/list?page=3&size=abc&active=on&ratio=0.5&since=2024-01-02T03:04:05Z&ttl=1h30m&ids=1,2&ids=3&empty=", nil)
+	ctx := &Context{R: r}
+
+	if page, err := ctx.QueryInt("page", 1); page != 3 || err != nil {
+		t.Fatalf("page = %d %v", page, err)
+	}
+	if missing, err := ctx.QueryInt("missing", 7); missing != 7 || err != nil {
+		t.Fatalf("missing = %d %v", missing, err)
+	}
+	if empty, err := ctx.QueryInt("empty", 7); empty != 7 || err != nil {
+		t.Fatalf("empty = %d %v", empty, err)
+	}
+	size, err := ctx.QueryInt("size", 10)
+	var paramErr *ParamError
+	if size != 10 || !errors.As(err, &paramErr) || paramErr.Key != "size" || paramErr.Source != "query" {
+		t.Fatalf("size = %d %v", size, err)
+	}
+	if !errors.Is(err, strconv.ErrSyntax) {
+		t.Fatalf("err should wrap strconv.ErrSyntax: %v", err)
+	}
+	if active, err := ctx.QueryBool("active", false); !active || err != nil {
+		t.Fatalf("active = %v %v", active, err)
+	}
+	if ratio, err := ctx.QueryFloat("ratio", 0); ratio != 0.5 || err != nil {
+		t.Fatalf("ratio = %v %v", ratio, err)
+	}
+	if since, err := ctx.QueryTime("since", "", time.Time{}); since.Year() != 2024 || err != nil {
+		t.Fatalf("since = %v %v", since, err)
+	}
+	if ttl, err := ctx.QueryDuration("ttl", 0); ttl != 90*time.Minute || err != nil {
+		t.Fatalf("ttl = %v %v", ttl, err)
+	}
+	ids, err := ctx.QueryIntSlice("ids")
+	if err != nil || len(ids) != 3 || ids[0] != 1 || ids[2] != 3 {
+		t.Fatalf("ids = %v %v", ids, err)
+	}
+	if _, err := ctx.QueryIntSlice("size"); err == nil {
+		t.Fatal("invalid slice should return an error")
+	}
+}
+
+func TestFormAccessorsIgnoreQuery(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/user/form?age=1&tag=query", strings.NewReader("age=18&tag=a&tag=b&agree=on"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	ctx := &Context{R: r}
+
+	if age, err := ctx.PostFormInt("age", 0); age != 18 || err != nil {
+		t.Fatalf("age = %d %v", age, err)
+	}
+	if tags := ctx.PostFormArr("tag"); len(tags) != 2 || tags[0] != "a" {
+		t.Fatalf("tags = %v", tags)
+	}
+	if agree, err := ctx.PostFormBool("agree", false); !agree || err != nil {
+		t.Fatalf("agree = %v %v", agree, err)
+	}
+	if page := ctx.GetDefaultPostForm("page", "1"); page != "1" {
+		t.Fatalf("page = %q", page)
+	}
+	// 只在查询参数中的值不能从表单读取
+	if _, ok := ctx.GetPostForm("missing"); ok {
+		t.Fatal("unexpected form value")
+	}
+	r = httptest.NewRequest(http.MethodPost, "/user/form?only=query", strings.NewReader(""))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	ctx = &Context{R: r}
+	if values := ctx.PostFormArr("only"); values != nil {
+		t.Fatalf("form read query string: %v", values)
+	}
+}
@@ -0,0 +1,93 @@
+package msgo
+
+import (
+	"fmt"
+	"github.com/demo-go/msgo/ratelimit"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type RateLimitConfig struct {
+	// Limiter 必须设置 例如 ratelimit.NewTokenBucket 或 ratelimit.NewSlidingWindow
+	Limiter ratelimit.Limiter
+	// KeyFunc 区分不同的调用方 默认 RateLimitByIP 返回空字符串时不限流
+	KeyFunc func(ctx *Context) string
+	// LimitReachedHandler 被限流时调用 默认返回 429
+	LimitReachedHandler HandleFunc
+	// FailClosed 为 true 时 Store 出错返回 503 默认放行请求
+	FailClosed bool
+}
+
+// RateLimitByIP 按客户端 IP 限流 需要配合 Engine.SetTrustedProxies 才能识别代理后面的真实 IP
+func RateLimitByIP(ctx *Context) string {
+	return "ip:" + ctx.ClientIP()
+}
+
+// RateLimitByHeader 按请求头限流 例如 API key 请求头不存在时不限流
+func RateLimitByHeader(name string) func(ctx *Context) string {
+	return func(ctx *Context) string {
+		value := ctx.R.Header.Get(name)
+		if value == "" {
+			return ""
+		}
+		return "header:" + name + ":" + value
+	}
+}
+
+// RateLimit 限流中间件 响应中带有 RateLimit-Limit RateLimit-Remaining RateLimit-Reset
+// 被限流时返回 429 和 Retry-After
+func RateLimit(config RateLimitConfig) MiddlewareFunc {
+	if config.Limiter == nil {
+		panic("msgo: RateLimitConfig.Limiter is required")
+	}
+	if config.KeyFunc == nil {
+		config.KeyFunc = RateLimitByIP
+	}
+	if config.LimitReachedHandler == nil {
+		config.LimitReachedHandler = func(ctx *Context) {
+			ctx.W.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprintf(ctx.W, "%s too many requests \n", ctx.R.RequestURI)
+		}
+	}
+	return func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			key := config.KeyFunc(ctx)
+			if key == "" {
+				next(ctx)
+				return
+			}
+			result, err := config.Limiter.Allow(ctx, key)
+			if err != nil {
+				log.Printf("msgo: rate limit %s: %v", key, err)
+				if config.FailClosed {
+					ctx.W.WriteHeader(http.StatusServiceUnavailable)
+					fmt.Fprintf(ctx.W, "%s service unavailable \n", ctx.R.RequestURI)
+					return
+				}
+				next(ctx)
+				return
+			}
+			header := ctx.W.Header()
+			header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			header.Set("RateLimit-Reset", ceilSeconds(result.Reset))
+			if !result.Allowed {
+				header.Set("Retry-After", ceilSeconds(result.RetryAfter))
+				config.LimitReachedHandler(ctx)
+				return
+			}
+			next(ctx)
+		}
+	}
+}
+
+// ceilSeconds 响应头中的秒数向上取整 避免客户端过早重试
+func ceilSeconds(d time.Duration) string {
+	if d <= 0 {
+		return "0"
+	}
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,142 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Result 一次限流判断的结果 用于设置 RateLimit-* 和 Retry-After 响应头
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset 距离额度完全恢复的时间
+	Reset time.Duration
+	// RetryAfter 被拒绝时 距离下一次可以请求的时间
+	RetryAfter time.Duration
+}
+
+type Limiter interface {
+	Allow(ctx context.Context, key string) (Result, error)
+}
+
+// TokenBucket 每 Period 补充 Limit 个令牌 桶中最多有 Burst 个令牌 允许短时间的突发请求
+type TokenBucket struct {
+	Store  Store
+	Limit  int
+	Period time.Duration
+	// Burst 默认等于 Limit
+	Burst int
+}
+
+func NewTokenBucket(store Store, limit int, period time.Duration, burst int) *TokenBucket {
+	if limit <= 0 || period <= 0 {
+		panic("ratelimit: limit and period must be positive")
+	}
+	if burst <= 0 {
+		burst = limit
+	}
+	return &TokenBucket{Store: store, Limit: limit, Period: period, Burst: burst}
+}
+
+func (b *TokenBucket) Allow(ctx context.Context, key string) (Result, error) {
+	now := time.Now()
+	// 每秒补充的令牌数
+	rate := float64(b.Limit) / b.Period.Seconds()
+	burst := float64(b.Burst)
+	// 桶从空到满需要的时间 之后状态等同于新建 可以过期
+	ttl := time.Duration(burst / rate * float64(time.Second))
+	var result Result
+	err := b.Store.Update(ctx, key, ttl, func(state *State, exists bool) {
+		tokens := burst
+		if exists {
+			elapsed := now.Sub(state.Last).Seconds()
+			if elapsed < 0 {
+				elapsed = 0
+			}
+			tokens = math.Min(burst, state.Tokens+elapsed*rate)
+		}
+		result = Result{Limit: b.Burst}
+		if tokens >= 1 {
+			tokens--
+			result.Allowed = true
+		} else {
+			result.RetryAfter = seconds((1 - tokens) / rate)
+		}
+		state.Tokens = tokens
+		state.Last = now
+		result.Remaining = int(tokens)
+		result.Reset = seconds((burst - tokens) / rate)
+	})
+	return result, err
+}
+
+// SlidingWindow 任意 Window 长度的时间内最多允许 Limit 个请求
+// 按上一个窗口的请求数加权估算 只需要保存两个计数
+type SlidingWindow struct {
+	Store  Store
+	Limit  int
+	Window time.Duration
+}
+
+func NewSlidingWindow(store Store, limit int, window time.Duration) *SlidingWindow {
+	if limit <= 0 || window <= 0 {
+		panic("ratelimit: limit and window must be positive")
+	}
+	return &SlidingWindow{Store: store, Limit: limit, Window: window}
+}
+
+func (s *SlidingWindow) Allow(ctx context.Context, key string) (Result, error) {
+	now := time.Now()
+	start := now.Truncate(s.Window)
+	limit := float64(s.Limit)
+	var result Result
+	err := s.Store.Update(ctx, key, 2*s.Window, func(state *State, exists bool) {
+		if !exists {
+			*state = State{WindowStart: start}
+		}
+		switch {
+		case state.WindowStart.Equal(start):
+		case state.WindowStart.Add(s.Window).Equal(start):
+			state.PrevCount, state.Count = state.Count, 0
+			state.WindowStart = start
+		default:
+			// 已经超过两个窗口没有请求
+			*state = State{WindowStart: start}
+		}
+		elapsed := now.Sub(start)
+		weight := 1 - float64(elapsed)/float64(s.Window)
+		prev, curr := float64(state.PrevCount), float64(state.Count)
+		estimated := prev*weight + curr
+
+		result = Result{Limit: s.Limit, Reset: s.Window - elapsed}
+		if estimated+1 <= limit {
+			state.Count++
+			estimated++
+			result.Allowed = true
+		} else {
+			result.RetryAfter = s.retryAfter(elapsed, prev, curr)
+		}
+		result.Remaining = int(math.Max(0, limit-estimated))
+	})
+	return result, err
+}
+
+// retryAfter 计算加权后的请求数降到 Limit-1 以下需要等待的时间
+func (s *SlidingWindow) retryAfter(elapsed time.Duration, prev, curr float64) time.Duration {
+	window := float64(s.Window)
+	allowed := float64(s.Limit) - 1
+	if curr > allowed {
+		// 当前窗口已经用完 要等到下一个窗口 当前窗口的计数成为上一个窗口
+		wait := float64(s.Window-elapsed) + math.Max(0, window*(1-allowed/curr))
+		return time.Duration(wait)
+	}
+	// 等上一个窗口的权重降下来
+	until := window * (1 - (allowed-curr)/prev)
+	return time.Duration(until) - elapsed
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
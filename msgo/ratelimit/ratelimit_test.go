@@ -0,0 +1,137 @@
+package ratelimit_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/demo-go/msgo"
+	"github.com/demo-go/msgo/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	store := ratelimit.NewMemoryStore(ratelimit.MemoryStoreConfig{})
+	defer store.Close()
+	limiter := ratelimit.NewTokenBucket(store, 1, time.Hour, 3)
+
+	for i := 0; i < 3; i++ {
+		result, err := limiter.Allow(context.Background(), "a")
+		if err != nil || !result.Allowed {
+			t.Fatalf("request %d rejected: %+v %v", i, result, err)
+		}
+		if result.Remaining != 2-i {
+			t.Fatalf("remaining = %d", result.Remaining)
+		}
+	}
+	result, _ := limiter.Allow(context.Background(), "a")
+	if result.Allowed || result.RetryAfter <= 59*time.Minute || result.RetryAfter > time.Hour {
+		t.Fatalf("burst exceeded: %+v", result)
+	}
+	// 不同的 key 互不影响
+	if result, _ := limiter.Allow(context.Background(), "b"); !result.Allowed {
+		t.Fatal("other key rejected")
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	store := ratelimit.NewMemoryStore(ratelimit.MemoryStoreConfig{})
+	defer store.Close()
+	limiter := ratelimit.NewSlidingWindow(store, 2, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		if result, _ := limiter.Allow(context.Background(), "a"); !result.Allowed {
+			t.Fatalf("request %d rejected", i)
+		}
+	}
+	result, _ := limiter.Allow(context.Background(), "a")
+	if result.Allowed || result.Remaining != 0 || result.RetryAfter <= 0 {
+		t.Fatalf("limit exceeded: %+v", result)
+	}
+}
+
+func TestMemoryStoreEviction(t *testing.T) {
+	store := ratelimit.NewMemoryStore(ratelimit.MemoryStoreConfig{MaxKeys: 2})
+	defer store.Close()
+	limiter := ratelimit.NewTokenBucket(store, 1, time.Hour, 1)
+	for _, key := range []string{"a", "b", "c"} {
+		limiter.Allow(context.Background(), key)
+	}
+	if store.Len() != 2 {
+		t.Fatalf("len = %d", store.Len())
+	}
+	// a 被淘汰后状态重新开始
+	if result, _ := limiter.Allow(context.Background(), "a"); !result.Allowed {
+		t.Fatal("evicted key should start over")
+	}
+	if result, _ := limiter.Allow(context.Background(), "c"); result.Allowed {
+		t.Fatal("recent key should keep its state")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	store := ratelimit.NewMemoryStore(ratelimit.MemoryStoreConfig{})
+	defer store.Close()
+	engine := msgo.New()
+	g := engine.Group("user")
+	g.Post("/jsonParam", func(ctx *msgo.Context) {
+		ctx.W.WriteHeader(http.StatusOK)
+	}, msgo.RateLimit(msgo.RateLimitConfig{
+		Limiter: ratelimit.NewTokenBucket(store, 1, time.Minute, 1),
+		KeyFunc: msgo.RateLimitByHeader("X-API-Key"),
+	}))
+
+	send := func(key string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/user/jsonParam", nil)
+		if key != "" {
+			r.Header.Set("X-API-Key", key)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		return w
+	}
+	w := send("k1")
+	if w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "1" || w.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("first request: %d %v", w.Code, w.Header())
+	}
+	w = send("k1")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" {
+		t.Fatalf("second request: %d %v", w.Code, w.Header())
+	}
+	// 没有请求头时不限流
+	for i := 0; i < 3; i++ {
+		if w := send(""); w.Code != http.StatusOK {
+			t.Fatalf("anonymous request %d: %d", i, w.Code)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	engine := msgo.New()
+	if err := engine.SetTrustedProxies([]string{"10.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+	var got string
+	engine.Group("user").Get("/ip", func(ctx *msgo.Context) {
+		got = msgo.RateLimitByIP(ctx)
+	})
+	cases := []struct {
+		remote, forwarded, want string
+	}{
+		{"203.0.113.1:1234", "198.51.100.7", "203.0.113.1"},
+		{"10.0.0.1:1234", "198.51.100.7, 10.0.0.2", "198.51.100.7"},
+		{"10.0.0.1:1234", "", "10.0.0.1"},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/user/ip", nil)
+		r.RemoteAddr = tc.remote
+		if tc.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", tc.forwarded)
+		}
+		engine.ServeHTTP(httptest.NewRecorder(), r)
+		if want := fmt.Sprintf("ip:%s", tc.want); got != want {
+			t.Fatalf("remote %s forwarded %q: got %s want %s", tc.remote, tc.forwarded, got, want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package ratelimit
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// State 限流算法保存在 Store 中的状态
+type State struct {
+	// Tokens 和 Last 用于令牌桶 剩余令牌和上次补充令牌的时间
+	Tokens float64
+	Last   time.Time
+	// WindowStart Count 和 PrevCount 用于滑动窗口 当前窗口的开始时间 当前和上一个窗口的请求数
+	WindowStart time.Time
+	Count       int64
+	PrevCount   int64
+}
+
+// Store 保存每个 key 的限流状态
+// Update 必须是原子的 读取 key 的状态交给 fn 修改后保存 并在 ttl 之后过期
+// 例如 Redis 可以用 WATCH/MULTI 或 Lua 脚本实现
+type Store interface {
+	Update(ctx context.Context, key string, ttl time.Duration, fn func(state *State, exists bool)) error
+}
+
+type MemoryStoreConfig struct {
+	// MaxKeys 最多保存的 key 数量 超过后淘汰最久没有访问的 默认 100000
+	MaxKeys int
+	// CleanupInterval 清理过期 key 的间隔 默认 1 分钟
+	CleanupInterval time.Duration
+}
+
+type memoryEntry struct {
+	key     string
+	state   State
+	expires time.Time
+}
+
+// MemoryStore 进程内的 Store 多个实例之间不共享状态
+type MemoryStore struct {
+	maxKeys int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+func NewMemoryStore(config MemoryStoreConfig) *MemoryStore {
+	if config.MaxKeys <= 0 {
+		config.MaxKeys = 100000
+	}
+	if config.CleanupInterval <= 0 {
+		config.CleanupInterval = time.Minute
+	}
+	s := &MemoryStore{
+		maxKeys: config.MaxKeys,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		stopCh:  make(chan struct{}),
+	}
+	go s.cleanup(config.CleanupInterval)
+	return s
+}
+
+func (s *MemoryStore) Update(ctx context.Context, key string, ttl time.Duration, fn func(state *State, exists bool)) error {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if element, ok := s.entries[key]; ok {
+		entry := element.Value.(*memoryEntry)
+		exists := now.Before(entry.expires)
+		if !exists {
+			entry.state = State{}
+		}
+		fn(&entry.state, exists)
+		entry.expires = now.Add(ttl)
+		s.lru.MoveToFront(element)
+		return nil
+	}
+	entry := &memoryEntry{key: key, expires: now.Add(ttl)}
+	fn(&entry.state, false)
+	s.entries[key] = s.lru.PushFront(entry)
+	for s.lru.Len() > s.maxKeys {
+		s.removeElement(s.lru.Back())
+	}
+	return nil
+}
+
+// Len 返回当前保存的 key 数量 包括还没有被清理的过期 key
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
+
+// Close 停止后台的清理
+func (s *MemoryStore) Close() error {
+	s.stopOnce.Do(func() {
+		close(s.stopCh)
+	})
+	return nil
+}
+
+func (s *MemoryStore) cleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.deleteExpired()
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+func (s *MemoryStore) deleteExpired() {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for element := s.lru.Back(); element != nil; {
+		prev := element.Prev()
+		if now.After(element.Value.(*memoryEntry).expires) {
+			s.removeElement(element)
+		}
+		element = prev
+	}
+}
+
+func (s *MemoryStore) removeElement(element *list.Element) {
+	s.lru.Remove(element)
+	delete(s.entries, element.Value.(*memoryEntry).key)
+}
@@ -1,6 +1,7 @@
 package render
 
 import (
+	"fmt"
 	"github.com/demo-go/msgo/internal/bytesconv"
 	"html/template"
 	"net/http"
@@ -13,10 +14,51 @@ type HTML struct {
 	IsTemplate bool
 }
 
-type HTMLRender struct {
+// HTMLRender 根据模板名称创建一个可以渲染的 Render
+// 以前的 HTMLRender 结构体现在是 HTMLProduction 直接给 Engine.HTMLRender 赋值的代码
+// 需要改为 &render.HTMLProduction{Template: t} 或者调用 Engine.SetHtmlTemplate
+type HTMLRender interface {
+	Instance(name string, data any) (Render, error)
+}
+
+// HTMLProduction 所有模板在同一个集合中 对应 Engine.LoadTemplate
+type HTMLProduction struct {
 	Template *template.Template
 }
 
+func (h *HTMLProduction) Instance(name string, data any) (Render, error) {
+	if h.Template == nil || h.Template.Lookup(name) == nil {
+		return nil, fmt.Errorf("html template %q is not defined", name)
+	}
+	return &HTML{
+		Name:       name,
+		Data:       data,
+		Template:   h.Template,
+		IsTemplate: true,
+	}, nil
+}
+
+// HTMLDebug 每次渲染前检查模板文件是否修改 对应 debug 模式下的 Engine.LoadTemplate
+type HTMLDebug struct {
+	Set *TemplateSet
+}
+
+func (h *HTMLDebug) Instance(name string, data any) (Render, error) {
+	t, err := h.Set.Template()
+	if err != nil {
+		return nil, err
+	}
+	if t.Lookup(name) == nil {
+		return nil, fmt.Errorf("html template %q is not defined", name)
+	}
+	return &HTML{
+		Name:       name,
+		Data:       data,
+		Template:   t,
+		IsTemplate: true,
+	}, nil
+}
+
 func (h *HTML) Render(w http.ResponseWriter) error {
 	h.WriteContentType(w)
 	if h.IsTemplate {
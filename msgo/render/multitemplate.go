@@ -0,0 +1,118 @@
+package render
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MultiTemplate 每个页面对应一个独立的模板集合 页面之间可以定义同名的模板
+// Reload 为 true 时 页面的模板文件修改后会重新解析
+type MultiTemplate struct {
+	FuncMap template.FuncMap
+	Reload  bool
+	mu      sync.RWMutex
+	pages   map[string]*pageTemplate
+}
+
+type pageTemplate struct {
+	// entry 渲染页面时执行的模板名 使用布局时是布局文件名
+	entry string
+	set   *TemplateSet
+}
+
+func NewMultiTemplate(funcMap template.FuncMap, reload bool) *MultiTemplate {
+	return &MultiTemplate{
+		FuncMap: funcMap,
+		Reload:  reload,
+		pages:   make(map[string]*pageTemplate),
+	}
+}
+
+// Add 直接添加一个已经解析好的模板集合 执行时使用 entry 模板
+func (m *MultiTemplate) Add(page string, t *template.Template, entry string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pages[page] = &pageTemplate{entry: entry, set: NewTemplateSet(t)}
+}
+
+// AddFromFiles 用多个文件组成一个页面 执行第一个文件
+func (m *MultiTemplate) AddFromFiles(page string, files ...string) error {
+	return m.AddFromFS(nil, page, files...)
+}
+
+// AddFromFS 和 AddFromFiles 相同 但文件从 fsys 中读取 fsys 为空时读取本地文件
+func (m *MultiTemplate) AddFromFS(fsys fs.FS, page string, files ...string) error {
+	if len(files) == 0 {
+		return fmt.Errorf("html template %q: no files", page)
+	}
+	entry := path.Base(filepath.ToSlash(files[0]))
+	set := &TemplateSet{
+		Name:    entry,
+		FS:      fsys,
+		Files:   files,
+		FuncMap: m.FuncMap,
+		Reload:  m.Reload,
+	}
+	if _, err := set.Template(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pages[page] = &pageTemplate{entry: entry, set: set}
+	return nil
+}
+
+// AddLayout 每个页面文件和布局文件以及公共片段组成一个集合 页面名是页面文件名
+// 布局中通过 {{ block "content" . }} 留出位置 页面中用 {{ define "content" }} 填充
+func (m *MultiTemplate) AddLayout(layout string, pages []string, includes ...string) error {
+	return m.AddLayoutFS(nil, layout, pages, includes...)
+}
+
+func (m *MultiTemplate) AddLayoutFS(fsys fs.FS, layout string, pages []string, includes ...string) error {
+	for _, page := range pages {
+		files := make([]string, 0, len(includes)+2)
+		files = append(files, layout)
+		files = append(files, includes...)
+		files = append(files, page)
+		if err := m.AddFromFS(fsys, path.Base(filepath.ToSlash(page)), files...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MultiTemplate) Instance(name string, data any) (Render, error) {
+	m.mu.RLock()
+	p, ok := m.pages[name]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("html template %q is not defined, available pages: %s", name, strings.Join(m.Pages(), ", "))
+	}
+	t, err := p.set.Template()
+	if err != nil {
+		return nil, err
+	}
+	return &HTML{
+		Name:       p.entry,
+		Data:       data,
+		Template:   t,
+		IsTemplate: true,
+	}, nil
+}
+
+func (m *MultiTemplate) Pages() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	names := make([]string, 0, len(m.pages))
+	for name := range m.pages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,77 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type SSEvent struct {
+	Event string
+	ID    string
+	Retry uint
+	Data  any
+}
+
+var fieldReplacer = strings.NewReplacer("\n", "", "\r", "")
+
+// lineReplacer \r\n \r 和 \n 都是 SSE 的行结束符 统一成 \n 再拆分
+var lineReplacer = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+func (s *SSEvent) Render(w http.ResponseWriter) error {
+	s.WriteContentType(w)
+	return s.Encode(w)
+}
+
+// Encode 按照 text/event-stream 格式写出事件 多行 data 拆成多个 data 字段
+func (s *SSEvent) Encode(w io.Writer) error {
+	var buf bytes.Buffer
+	if s.ID != "" {
+		buf.WriteString("id: " + fieldReplacer.Replace(s.ID) + "\n")
+	}
+	if s.Event != "" {
+		buf.WriteString("event: " + fieldReplacer.Replace(s.Event) + "\n")
+	}
+	if s.Retry > 0 {
+		buf.WriteString(fmt.Sprintf("retry: %d\n", s.Retry))
+	}
+	data, err := sseData(s.Data)
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(lineReplacer.Replace(data), "\n") {
+		buf.WriteString("data: " + line + "\n")
+	}
+	buf.WriteString("\n")
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func sseData(data any) (string, error) {
+	switch v := data.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case fmt.Stringer:
+		return v.String(), nil
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+}
+
+func (s *SSEvent) WriteContentType(w http.ResponseWriter) {
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+}
@@ -0,0 +1,32 @@
+package render_test
+
+import (
+	"bytes"
+	"github.com/demo-go/msgo/render"
+	"testing"
+)
+
+func TestSSEventEncode(t *testing.T) {
+	cases := []struct {
+		event render.SSEvent
+		want  string
+	}{
+		{render.SSEvent{Event: "message", ID: "1", Retry: 3000, Data: "hello"},
+			"id: 1\nevent: message\nretry: 3000\ndata: hello\n\n"},
+		{render.SSEvent{Data: map[string]int{"count": 1}}, "data: {\"count\":1}\n\n"},
+		// 多行 data 拆成多个 data 字段 三种行结束符都要处理
+		{render.SSEvent{Data: "a\nb\r\nc\rd"}, "data: a\ndata: b\ndata: c\ndata: d\n\n"},
+		// 不能通过 \r 注入新的字段
+		{render.SSEvent{Data: "x\revent: admin"}, "data: x\ndata: event: admin\n\n"},
+		{render.SSEvent{Event: "tick\r\nid: 2", ID: "1\r"}, "id: 1\nevent: tickid: 2\ndata: \n\n"},
+	}
+	for _, c := range cases {
+		var buf bytes.Buffer
+		if err := c.event.Encode(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != c.want {
+			t.Errorf("Encode(%+v) = %q want %q", c.event, buf.String(), c.want)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package render
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// TemplateSet 由一组文件解析出的模板集合 第一次使用时解析并缓存
+// Reload 为 true 时每次获取都会比较文件的修改时间 有变化则重新解析
+// FS 不为空时从 FS 中读取文件 否则读取本地文件系统
+type TemplateSet struct {
+	Name     string
+	FS       fs.FS
+	Files    []string
+	Patterns []string
+	FuncMap  template.FuncMap
+	Reload   bool
+
+	mu       sync.Mutex
+	template *template.Template
+	modTimes map[string]time.Time
+}
+
+func NewTemplateSet(t *template.Template) *TemplateSet {
+	return &TemplateSet{Name: t.Name(), template: t}
+}
+
+func (s *TemplateSet) Template() (*template.Template, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.template != nil && (!s.Reload || len(s.Files)+len(s.Patterns) == 0) {
+		return s.template, nil
+	}
+	files, err := s.resolve()
+	if err != nil {
+		return nil, err
+	}
+	modTimes, err := s.statFiles(files)
+	if err != nil {
+		return nil, err
+	}
+	if s.template != nil && sameModTimes(s.modTimes, modTimes) {
+		return s.template, nil
+	}
+	t := template.New(s.Name).Funcs(s.FuncMap)
+	if s.FS != nil {
+		t, err = t.ParseFS(s.FS, files...)
+	} else {
+		t, err = t.ParseFiles(files...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.template = t
+	s.modTimes = modTimes
+	return t, nil
+}
+
+func (s *TemplateSet) resolve() ([]string, error) {
+	files := append([]string{}, s.Files...)
+	for _, pattern := range s.Patterns {
+		var matches []string
+		var err error
+		if s.FS != nil {
+			matches, err = fs.Glob(s.FS, pattern)
+		} else {
+			matches, err = filepath.Glob(pattern)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("html/template: pattern matches no files: %#q", pattern)
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+func (s *TemplateSet) statFiles(files []string) (map[string]time.Time, error) {
+	modTimes := make(map[string]time.Time, len(files))
+	for _, file := range files {
+		var info fs.FileInfo
+		var err error
+		if s.FS != nil {
+			info, err = fs.Stat(s.FS, file)
+		} else {
+			info, err = os.Stat(file)
+		}
+		if err != nil {
+			return nil, err
+		}
+		modTimes[file] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+func sameModTimes(old, current map[string]time.Time) bool {
+	if len(old) != len(current) {
+		return false
+	}
+	for file, modTime := range current {
+		if t, ok := old[file]; !ok || !t.Equal(modTime) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,94 @@
+package render_test
+
+import (
+	"bytes"
+	"github.com/demo-go/msgo/render"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// writeTemplate 写入文件并把修改时间设置为 modTime 避免文件系统的时间精度导致修改时间不变
+func writeTemplate(t *testing.T, file, content string, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(file, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func execute(t *testing.T, set *render.TemplateSet) string {
+	t.Helper()
+	tpl, err := set.Template()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := tpl.ExecuteTemplate(&buf, "page.html", nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestTemplateSetReload(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "page.html")
+	now := time.Now()
+	writeTemplate(t, file, "v1", now.Add(-time.Hour))
+
+	reload := &render.TemplateSet{Name: "page.html", Patterns: []string{filepath.Join(dir, "*.html")}, Reload: true}
+	cached := &render.TemplateSet{Name: "page.html", Files: []string{file}}
+	if execute(t, reload) != "v1" || execute(t, cached) != "v1" {
+		t.Fatal("initial parse")
+	}
+	first, _ := reload.Template()
+	// 修改时间没有变化时使用缓存
+	if again, _ := reload.Template(); again != first {
+		t.Fatal("template parsed again without changes")
+	}
+
+	writeTemplate(t, file, "v2", now)
+	if got := execute(t, reload); got != "v2" {
+		t.Fatalf("reload = %q", got)
+	}
+	if got := execute(t, cached); got != "v1" {
+		t.Fatalf("release = %q", got)
+	}
+	// 新增的文件也会被 Patterns 匹配到
+	writeTemplate(t, filepath.Join(dir, "extra.html"), `{{ define "extra" }}x{{ end }}`, now)
+	if tpl, _ := reload.Template(); tpl.Lookup("extra") == nil {
+		t.Fatal("new file not picked up")
+	}
+}
+
+func TestMultiTemplate(t *testing.T) {
+	fsys := fstest.MapFS{
+		"layout.html": {Data: []byte(`[{{ block "content" . }}{{ end }}]`)},
+		"index.html":  {Data: []byte(`{{ define "content" }}index {{ . }}{{ end }}`)},
+		"login.html":  {Data: []byte(`{{ define "content" }}login{{ end }}`)},
+	}
+	m := render.NewMultiTemplate(nil, false)
+	if err := m.AddLayoutFS(fsys, "layout.html", []string{"index.html", "login.html"}); err != nil {
+		t.Fatal(err)
+	}
+	// 两个页面定义了同名的 content 模板 互不影响
+	for page, want := range map[string]string{"index.html": "[index dema]", "login.html": "[login]"} {
+		r, err := m.Instance(page, "dema")
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		if err := r.Render(w); err != nil || w.Body.String() != want {
+			t.Fatalf("%s = %q %v", page, w.Body, err)
+		}
+	}
+	if _, err := m.Instance("missing.html", nil); err == nil || !strings.Contains(err.Error(), "index.html, login.html") {
+		t.Fatalf("err = %v", err)
+	}
+}
@@ -0,0 +1,234 @@
+package msgo
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/demo-go/msgo/internal/tracectx"
+	"github.com/demo-go/msgo/tracing"
+	"io"
+	"log"
+	"net/http"
+	"os"
+)
+
+const (
+	HeaderXRequestID  = "X-Request-ID"
+	HeaderTraceParent = "traceparent"
+	HeaderTraceState  = "tracestate"
+)
+
+type requestIDKey struct{}
+
+type traceContextKey struct{}
+
+// TraceContext W3C Trace Context 中的信息
+// SpanID 是当前请求在本服务中的 span ParentID 是调用方传过来的 span 新链路时为空
+type TraceContext struct {
+	TraceID    string
+	SpanID     string
+	ParentID   string
+	Sampled    bool
+	TraceState string
+}
+
+// TraceParent 返回传给下游服务的 traceparent 以当前 span 作为下游的父节点
+func (t TraceContext) TraceParent() string {
+	flags := "00"
+	if t.Sampled {
+		flags = "01"
+	}
+	return "00-" + t.TraceID + "-" + t.SpanID + "-" + flags
+}
+
+type RequestIDConfig struct {
+	// Header 默认 X-Request-ID
+	Header string
+	// Generator 生成新的请求 id 默认生成 uuid v4
+	Generator func() string
+	// IgnoreIncoming 为 true 时不信任客户端传来的请求 id 和 traceparent
+	IgnoreIncoming bool
+}
+
+// RequestID 读取或生成请求 id 并解析或创建 traceparent 保存到请求的 context 中 同时写回响应头
+func RequestID(config RequestIDConfig) MiddlewareFunc {
+	if config.Header == "" {
+		config.Header = HeaderXRequestID
+	}
+	if config.Generator == nil {
+		config.Generator = newUUID
+	}
+	return func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			requestID := ""
+			if !config.IgnoreIncoming {
+				requestID = ctx.R.Header.Get(config.Header)
+			}
+			if !validRequestID(requestID) {
+				requestID = config.Generator()
+			}
+			// 开启了 tracing 时沿用 SERVER span 的信息
+			trace, ok := TraceContextFromContext(ctx.R.Context())
+			if !ok {
+				trace = newTraceContext(ctx.R.Header, config.IgnoreIncoming)
+			}
+
+			c := context.WithValue(ctx.R.Context(), requestIDKey{}, requestID)
+			c = context.WithValue(c, traceContextKey{}, trace)
+			ctx.R = ctx.R.WithContext(c)
+
+			header := ctx.W.Header()
+			header.Set(config.Header, requestID)
+			header.Set(HeaderTraceParent, trace.TraceParent())
+			if trace.TraceState != "" {
+				header.Set(HeaderTraceState, trace.TraceState)
+			}
+			next(ctx)
+		}
+	}
+}
+
+func newTraceContext(header http.Header, ignoreIncoming bool) TraceContext {
+	spanID := tracectx.NewSpanID()
+	if !ignoreIncoming {
+		if parent, ok := tracectx.Parse(header.Get(HeaderTraceParent)); ok {
+			trace := TraceContext{
+				TraceID:  hex.EncodeToString(parent.TraceID[:]),
+				SpanID:   hex.EncodeToString(spanID[:]),
+				ParentID: hex.EncodeToString(parent.ParentID[:]),
+				Sampled:  parent.Sampled(),
+			}
+			if state := header.Get(HeaderTraceState); tracectx.ValidTraceState(state) {
+				trace.TraceState = state
+			}
+			return trace
+		}
+	}
+	traceID := tracectx.NewTraceID()
+	return TraceContext{
+		TraceID: hex.EncodeToString(traceID[:]),
+		SpanID:  hex.EncodeToString(spanID[:]),
+		Sampled: true,
+	}
+}
+
+// validRequestID 只接受长度有限的可见 ASCII 字符 防止日志注入
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func TraceContextFromContext(ctx context.Context) (TraceContext, bool) {
+	trace, ok := ctx.Value(traceContextKey{}).(TraceContext)
+	return trace, ok
+}
+
+func (c *Context) RequestID() string {
+	return RequestIDFromContext(c.R.Context())
+}
+
+func (c *Context) TraceContext() (TraceContext, bool) {
+	return TraceContextFromContext(c.R.Context())
+}
+
+// Logger 返回带有请求 id 和 trace id 前缀的日志对象
+func (c *Context) Logger() *log.Logger {
+	prefix := ""
+	if id := c.RequestID(); id != "" {
+		prefix = "[" + id + "] "
+	}
+	if trace, ok := c.TraceContext(); ok {
+		prefix += "[trace " + trace.TraceID + "] "
+	}
+	return log.New(os.Stderr, prefix, log.LstdFlags)
+}
+
+// NewRequest 创建调用下游服务的请求 继承当前请求的 context 并带上请求 id 和 traceparent
+func (c *Context) NewRequest(method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(c.R.Context(), method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	InjectHeaders(c.R.Context(), req.Header)
+	return req, nil
+}
+
+// InjectHeaders 把 ctx 中的请求 id 和 trace 信息写入下游请求的请求头
+func InjectHeaders(ctx context.Context, header http.Header) {
+	if id := RequestIDFromContext(ctx); id != "" && header.Get(HeaderXRequestID) == "" {
+		header.Set(HeaderXRequestID, id)
+	}
+	if trace, ok := TraceContextFromContext(ctx); ok && header.Get(HeaderTraceParent) == "" {
+		header.Set(HeaderTraceParent, trace.TraceParent())
+		if trace.TraceState != "" {
+			header.Set(HeaderTraceState, trace.TraceState)
+		}
+	}
+}
+
+// PropagationTransport 发出请求前根据 req.Context() 注入请求 id 和 traceparent
+type PropagationTransport struct {
+	Base http.RoundTripper
+	// Context 不为空时 req.Context() 中没有的值从这里读取 例如使用 http.Get 发出的请求
+	// 取消和截止时间仍然以 req.Context() 为准
+	Context context.Context
+}
+
+func (t *PropagationTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	ctx := req.Context()
+	if t.Context != nil {
+		ctx = valuesContext{Context: ctx, values: t.Context}
+	}
+	// RoundTripper 不能修改传入的请求
+	req = req.Clone(ctx)
+	InjectHeaders(ctx, req.Header)
+	return base.RoundTrip(req)
+}
+
+// valuesContext 使用 Context 的取消和截止时间 Context 中没有的值再从 values 中读取
+type valuesContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c valuesContext) Value(key any) any {
+	if v := c.Context.Value(key); v != nil {
+		return v
+	}
+	return c.values.Value(key)
+}
+
+// HTTPClient 返回会自动传递请求 id 和 traceparent 的 http.Client 设置了 Tracer 时还会记录 CLIENT span
+// 请求没有设置 context 时也会使用当前请求的请求 id 和 trace 信息
+func (c *Context) HTTPClient() *http.Client {
+	transport := &PropagationTransport{Context: c.R.Context()}
+	if c.engine != nil && c.engine.Tracer != nil {
+		transport.Base = &tracing.Transport{Tracer: c.engine.Tracer}
+	}
+	return &http.Client{Transport: transport}
+}
@@ -0,0 +1,85 @@
+package msgo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const incomingTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestRequestID(t *testing.T) {
+	engine := New()
+	g := engine.Group("user")
+	g.Use(RequestID(RequestIDConfig{}))
+	var id string
+	var trace TraceContext
+	g.Get("/info", func(ctx *Context) {
+		id = ctx.RequestID()
+		trace, _ = ctx.TraceContext()
+	})
+
+	send := func(headers ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/user/info", nil)
+		for i := 0; i+1 < len(headers); i += 2 {
+			r.Header.Set(headers[i], headers[i+1])
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		return w
+	}
+
+	w := send(HeaderXRequestID, "abc-123", HeaderTraceParent, incomingTraceParent, HeaderTraceState, "vendor=value")
+	if id != "abc-123" || w.Header().Get(HeaderXRequestID) != "abc-123" {
+		t.Fatalf("request id = %q", id)
+	}
+	// 沿用调用方的 trace id 调用方的 span 成为父节点
+	if trace.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || trace.ParentID != "00f067aa0ba902b7" ||
+		trace.SpanID == trace.ParentID || !trace.Sampled || trace.TraceState != "vendor=value" {
+		t.Fatalf("trace = %+v", trace)
+	}
+	if w.Header().Get(HeaderTraceParent) != trace.TraceParent() {
+		t.Fatalf("traceparent = %q", w.Header().Get(HeaderTraceParent))
+	}
+
+	// 非法的请求 id 和 traceparent 重新生成
+	send(HeaderXRequestID, "bad\nid", HeaderTraceParent, "00-zz-00f067aa0ba902b7-01")
+	if id == "bad\nid" || len(id) != 36 || trace.ParentID != "" || len(trace.TraceID) != 32 {
+		t.Fatalf("id = %q trace = %+v", id, trace)
+	}
+}
+
+func TestHTTPClientPropagation(t *testing.T) {
+	var got http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer upstream.Close()
+
+	engine := New()
+	g := engine.Group("user")
+	g.Use(RequestID(RequestIDConfig{}))
+	var trace TraceContext
+	g.Get("/info", func(ctx *Context) {
+		trace, _ = ctx.TraceContext()
+		// 没有使用 ctx.NewRequest 请求的 context 中没有请求 id
+		resp, err := ctx.HTTPClient().Get(upstream.URL)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		resp.Body.Close()
+	})
+	r := httptest.NewRequest(http.MethodGet, "/user/info", nil)
+	r.Header.Set(HeaderXRequestID, "abc-123")
+	r.Header.Set(HeaderTraceParent, incomingTraceParent)
+	engine.ServeHTTP(httptest.NewRecorder(), r)
+
+	if got.Get(HeaderXRequestID) != "abc-123" {
+		t.Fatalf("X-Request-ID = %q", got.Get(HeaderXRequestID))
+	}
+	if tp := got.Get(HeaderTraceParent); tp != trace.TraceParent() || !strings.Contains(tp, trace.TraceID) {
+		t.Fatalf("traceparent = %q want %q", tp, trace.TraceParent())
+	}
+}
@@ -0,0 +1,39 @@
+package msgo
+
+import (
+	"github.com/demo-go/msgo/tus"
+	"path"
+	"strings"
+)
+
+// Resumable 在 prefix 下挂载 tus 协议的断点续传接口
+// POST prefix 创建上传 HEAD PATCH DELETE prefix/:id 查询进度 追加数据和删除上传
+// OPTIONS 只经过分组中间件 路由级别的中间件 例如鉴权 不会拒绝 tus 的能力发现和 CORS 预检请求
+func (r *routerGroup) Resumable(prefix string, handler *tus.Handler, middlewareFunc ...MiddlewareFunc) {
+	if strings.Contains(prefix, ":") || strings.Contains(prefix, "*") {
+		panic("URL parameters can not be used in a resumable upload prefix")
+	}
+	prefix = "/" + strings.Trim(prefix, "/")
+	uploadID := func(ctx *Context) string {
+		name := strings.TrimPrefix(SubStringLast(ctx.R.URL.Path, "/"+r.name), prefix)
+		return strings.Trim(name, "/")
+	}
+	options := func(ctx *Context) {
+		handler.Options(ctx.W, ctx.R)
+	}
+	item := path.Join(prefix, ":id")
+	r.Options(prefix, options)
+	r.Post(prefix, func(ctx *Context) {
+		handler.Create(ctx.W, ctx.R)
+	}, middlewareFunc...)
+	r.Options(item, options)
+	r.Head(item, func(ctx *Context) {
+		handler.Head(ctx.W, ctx.R, uploadID(ctx))
+	}, middlewareFunc...)
+	r.Patch(item, func(ctx *Context) {
+		handler.Patch(ctx.W, ctx.R, uploadID(ctx))
+	}, middlewareFunc...)
+	r.Delete(item, func(ctx *Context) {
+		handler.Delete(ctx.W, ctx.R, uploadID(ctx))
+	}, middlewareFunc...)
+}
@@ -0,0 +1,129 @@
+package msgo
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/demo-go/msgo/sessions"
+	"net"
+	"net/http"
+	"time"
+)
+
+type SessionConfig struct {
+	// Store 默认使用 sessions.NewMemoryStore
+	Store sessions.Store
+	// CookieName 默认 msgo_session
+	CookieName string
+	// CookieOptions 默认使用 DefaultCookieOptions MaxAge 会被会话的过期时间代替
+	CookieOptions *CookieOptions
+	// IdleTimeout 超过这个时间没有请求时会话过期 默认 30 分钟
+	IdleTimeout time.Duration
+	// AbsoluteTimeout 会话创建之后的最长有效期 默认 24 小时
+	AbsoluteTimeout time.Duration
+}
+
+// Sessions 加载请求的会话 handler 通过 ctx.Session() 使用
+// 会话在响应开始写入之前保存 cookie 和会话在同一时间过期
+func Sessions(config SessionConfig) MiddlewareFunc {
+	if config.Store == nil {
+		config.Store = sessions.NewMemoryStore(0)
+	}
+	if config.CookieName == "" {
+		config.CookieName = "msgo_session"
+	}
+	if config.CookieOptions == nil {
+		options := DefaultCookieOptions
+		config.CookieOptions = &options
+	}
+	manager := sessions.NewManager(sessions.Config{
+		Store:           config.Store,
+		IdleTimeout:     config.IdleTimeout,
+		AbsoluteTimeout: config.AbsoluteTimeout,
+	})
+	return func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			token := ""
+			if cookie, err := ctx.R.Cookie(config.CookieName); err == nil {
+				token = cookie.Value
+			}
+			session, err := manager.Load(ctx, token)
+			if err != nil {
+				ctx.Logger().Printf("msgo: load session: %v", err)
+				ctx.W.WriteHeader(http.StatusInternalServerError)
+				fmt.Fprintf(ctx.W, "%s session unavailable \n", ctx.R.RequestURI)
+				return
+			}
+			commit := func() {
+				token, expiresAt, write, err := manager.Commit(ctx, session)
+				if err != nil {
+					ctx.Logger().Printf("msgo: save session: %v", err)
+					return
+				}
+				if !write {
+					return
+				}
+				options := *config.CookieOptions
+				if token == "" {
+					options.MaxAge = -1
+				} else {
+					options.MaxAge = int(time.Until(expiresAt).Round(time.Second) / time.Second)
+				}
+				ctx.setCookie(config.CookieName, token, options)
+			}
+			w := &sessionWriter{ResponseWriter: ctx.W, commit: commit}
+			ctx.W = w
+			ctx.session = session
+			defer func() {
+				ctx.W = w.ResponseWriter
+			}()
+			next(ctx)
+			w.beforeWrite()
+		}
+	}
+}
+
+// Session 返回 Sessions 中间件加载的会话 没有使用中间件时返回 nil
+func (c *Context) Session() *sessions.Session {
+	return c.session
+}
+
+// sessionWriter 在响应头发送之前保存会话 这时还可以设置 cookie
+type sessionWriter struct {
+	http.ResponseWriter
+	committed bool
+	commit    func()
+}
+
+func (w *sessionWriter) beforeWrite() {
+	if !w.committed {
+		w.committed = true
+		w.commit()
+	}
+}
+
+func (w *sessionWriter) WriteHeader(code int) {
+	w.beforeWrite()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *sessionWriter) Write(b []byte) (int, error) {
+	w.beforeWrite()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *sessionWriter) Flush() {
+	w.beforeWrite()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *sessionWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the ResponseWriter doesn't support the Hijacker interface")
+	}
+	w.beforeWrite()
+	return hijacker.Hijack()
+}
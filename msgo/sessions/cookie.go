@@ -0,0 +1,63 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/demo-go/msgo/internal/securecookie"
+)
+
+// maxCookieSize 浏览器对单个 cookie 的限制大约是 4096 字节 包括名字和属性
+const maxCookieSize = 3800
+
+// cookieName 加密时的附加数据 会话 cookie 的值不能用在其他 cookie 上
+const cookieName = "msgo session"
+
+// CookieStore 把整个会话加密后保存在 cookie 中 服务端不需要存储
+// 会话不能太大 Delete 无法让已经发出的 cookie 失效 只能等它过期
+type CookieStore struct {
+	codec *securecookie.Codec
+}
+
+// NewCookieStore 每个密钥至少 32 字节 第一个密钥用于加密 其余的只用于解密 用于轮换密钥
+func NewCookieStore(keys ...[]byte) (*CookieStore, error) {
+	codec, err := securecookie.New(keys...)
+	if err != nil {
+		return nil, err
+	}
+	return &CookieStore{codec: codec}, nil
+}
+
+func (s *CookieStore) Load(_ context.Context, token string) (*Record, error) {
+	data, err := s.codec.Decrypt(cookieName, token)
+	if errors.Is(err, securecookie.ErrInvalid) || errors.Is(err, securecookie.ErrExpired) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	record, err := DecodeRecord(data)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	return record, nil
+}
+
+func (s *CookieStore) Save(_ context.Context, record *Record) (string, error) {
+	data, err := EncodeRecord(record)
+	if err != nil {
+		return "", err
+	}
+	token, err := s.codec.Encrypt(cookieName, data, record.ExpiresAt.Unix())
+	if err != nil {
+		return "", err
+	}
+	if len(token) > maxCookieSize {
+		return "", fmt.Errorf("sessions: session is too large for a cookie (%d bytes)", len(token))
+	}
+	return token, nil
+}
+
+func (s *CookieStore) Delete(context.Context, string) error {
+	return nil
+}
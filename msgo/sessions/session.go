@@ -0,0 +1,283 @@
+// Package sessions 管理保存在服务端或者 cookie 中的会话
+// Manager 负责会话的加载 过期和保存 Store 负责存储 msgo.Sessions 中间件把它们和 cookie 连接起来
+package sessions
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	defaultIdleTimeout     = 30 * time.Minute
+	defaultAbsoluteTimeout = 24 * time.Hour
+	idSize                 = 32
+)
+
+// Record Store 中保存的会话数据
+type Record struct {
+	ID       string
+	Values   map[string]any
+	Flashes  map[string][]any
+	Created  time.Time
+	LastSeen time.Time
+	// ExpiresAt 空闲过期和绝对过期中较早的时间 Store 可以在这之后删除会话
+	ExpiresAt time.Time
+}
+
+// Session 一次请求中的会话 可以在多个 goroutine 中使用
+// 修改在响应开始写入之前保存 之后的修改会被丢弃
+type Session struct {
+	mu     sync.Mutex
+	record Record
+	// token 从 cookie 中读取的值 新的会话为空
+	token       string
+	isNew       bool
+	modified    bool
+	clearCookie bool
+	// oldTokens Regenerate 和 Destroy 之前的 token 提交时从 Store 删除
+	oldTokens []string
+}
+
+func newID() string {
+	b := make([]byte, idSize)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// ValidID 是否为 Manager 生成的 id 格式 Store 可以直接用它作为文件名或者 key
+func ValidID(id string) bool {
+	if len(id) != base64.RawURLEncoding.EncodedLen(idSize) {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Session) ID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.record.ID
+}
+
+// IsNew 请求没有带有有效的会话
+func (s *Session) IsNew() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.isNew
+}
+
+func (s *Session) Get(key string) (value any, exists bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, exists = s.record.Values[key]
+	return
+}
+
+func (s *Session) GetString(key string) string {
+	value, _ := s.Get(key)
+	str, _ := value.(string)
+	return str
+}
+
+func (s *Session) GetInt(key string) int {
+	value, _ := s.Get(key)
+	i, _ := value.(int)
+	return i
+}
+
+func (s *Session) GetBool(key string) bool {
+	value, _ := s.Get(key)
+	b, _ := value.(bool)
+	return b
+}
+
+// Set value 的类型需要能被 encoding/gob 编码 自定义类型需要先调用 Register
+func (s *Session) Set(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.record.Values == nil {
+		s.record.Values = make(map[string]any)
+	}
+	s.record.Values[key] = value
+	s.modified = true
+}
+
+func (s *Session) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.record.Values[key]; ok {
+		delete(s.record.Values, key)
+		s.modified = true
+	}
+}
+
+// Clear 删除所有的值和 flash 消息 会话本身仍然存在
+func (s *Session) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.record.Values = nil
+	s.record.Flashes = nil
+	s.modified = true
+}
+
+// Flash 添加一条只能读取一次的消息 常用于重定向之后显示提示
+func (s *Session) Flash(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.record.Flashes == nil {
+		s.record.Flashes = make(map[string][]any)
+	}
+	s.record.Flashes[key] = append(s.record.Flashes[key], value)
+	s.modified = true
+}
+
+// Flashes 返回并删除 key 下的所有 flash 消息
+func (s *Session) Flashes(key string) []any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	flashes, ok := s.record.Flashes[key]
+	if ok {
+		delete(s.record.Flashes, key)
+		s.modified = true
+	}
+	return flashes
+}
+
+// Regenerate 保留会话中的值 更换 id 并重新计算绝对过期时间 登录等权限变化之后调用 防止会话固定攻击
+func (s *Session) Regenerate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" {
+		s.oldTokens = append(s.oldTokens, s.token)
+		s.token = ""
+	}
+	s.record.ID = newID()
+	s.record.Created = time.Now()
+	s.modified = true
+}
+
+// Destroy 删除会话 之后的 Set 会创建一个新的会话
+func (s *Session) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" {
+		s.oldTokens = append(s.oldTokens, s.token)
+		s.token = ""
+		s.clearCookie = true
+	}
+	s.record = Record{ID: newID(), Created: time.Now()}
+	s.modified = false
+}
+
+type Config struct {
+	Store Store
+	// IdleTimeout 超过这个时间没有请求时会话过期 默认 30 分钟
+	IdleTimeout time.Duration
+	// AbsoluteTimeout 会话创建之后的最长有效期 默认 24 小时
+	AbsoluteTimeout time.Duration
+}
+
+type Manager struct {
+	store           Store
+	idleTimeout     time.Duration
+	absoluteTimeout time.Duration
+	// touchInterval 没有修改的会话最多每隔这么久保存一次 用来延长空闲过期时间
+	touchInterval time.Duration
+}
+
+func NewManager(config Config) *Manager {
+	if config.Store == nil {
+		panic("sessions: Config.Store is required")
+	}
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = defaultIdleTimeout
+	}
+	if config.AbsoluteTimeout <= 0 {
+		config.AbsoluteTimeout = defaultAbsoluteTimeout
+	}
+	touchInterval := config.IdleTimeout / 10
+	if touchInterval > time.Minute {
+		touchInterval = time.Minute
+	}
+	return &Manager{
+		store:           config.Store,
+		idleTimeout:     config.IdleTimeout,
+		absoluteTimeout: config.AbsoluteTimeout,
+		touchInterval:   touchInterval,
+	}
+}
+
+// Load 根据 cookie 中的 token 加载会话 token 为空 无效或者会话已经过期时返回新的会话
+// 只有 Store 出错时才返回错误
+func (m *Manager) Load(ctx context.Context, token string) (*Session, error) {
+	now := time.Now()
+	if token != "" {
+		record, err := m.store.Load(ctx, token)
+		switch {
+		case err == nil && !m.expired(record, now):
+			return &Session{record: *record, token: token}, nil
+		case err == nil:
+			if err := m.store.Delete(ctx, token); err != nil {
+				return nil, err
+			}
+		case !errors.Is(err, ErrNotFound):
+			return nil, err
+		}
+	}
+	return &Session{
+		record:      Record{ID: newID(), Created: now},
+		isNew:       true,
+		clearCookie: token != "",
+	}, nil
+}
+
+func (m *Manager) expired(record *Record, now time.Time) bool {
+	return now.After(record.LastSeen.Add(m.idleTimeout)) || now.After(record.Created.Add(m.absoluteTimeout))
+}
+
+// Commit 保存修改过的会话 多次调用只会保存一次
+// write 为 true 时需要把 token 写入 cookie 并在 expiresAt 过期 token 为空时需要删除 cookie
+// 没有任何值的新会话不会保存 避免为每个访问者创建会话
+func (m *Manager) Commit(ctx context.Context, s *Session) (token string, expiresAt time.Time, write bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.oldTokens) > 0 {
+		if err := m.store.Delete(ctx, s.oldTokens[0]); err != nil {
+			return "", time.Time{}, false, err
+		}
+		s.oldTokens = s.oldTokens[1:]
+	}
+	if s.token == "" && len(s.record.Values) == 0 && len(s.record.Flashes) == 0 {
+		write = s.clearCookie
+		s.clearCookie = false
+		return "", time.Time{}, write, nil
+	}
+	now := time.Now()
+	if s.token != "" && !s.modified && now.Sub(s.record.LastSeen) < m.touchInterval {
+		return s.token, s.record.ExpiresAt, false, nil
+	}
+	s.record.LastSeen = now
+	s.record.ExpiresAt = now.Add(m.idleTimeout)
+	if absolute := s.record.Created.Add(m.absoluteTimeout); absolute.Before(s.record.ExpiresAt) {
+		s.record.ExpiresAt = absolute
+	}
+	record := s.record
+	token, err = m.store.Save(ctx, &record)
+	if err != nil {
+		return "", time.Time{}, false, err
+	}
+	s.token = token
+	s.modified = false
+	s.clearCookie = false
+	return token, s.record.ExpiresAt, true, nil
+}
@@ -0,0 +1,189 @@
+package sessions_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/demo-go/msgo"
+	"github.com/demo-go/msgo/sessions"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// client 保存响应设置的 cookie 在之后的请求中带上
+type client struct {
+	engine  *msgo.Engine
+	cookies map[string]*http.Cookie
+}
+
+func (c *client) get(t *testing.T, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	for _, cookie := range c.cookies {
+		r.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	c.engine.ServeHTTP(w, r)
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.MaxAge < 0 {
+			delete(c.cookies, cookie.Name)
+		} else {
+			c.cookies[cookie.Name] = cookie
+		}
+	}
+	return w
+}
+
+func newClient(config msgo.SessionConfig) *client {
+	engine := msgo.New()
+	g := engine.Group("user")
+	g.Use(msgo.Sessions(config))
+	g.Get("/login", func(ctx *msgo.Context) {
+		session := ctx.Session()
+		session.Regenerate()
+		session.Set("user", ctx.GetQuery("name"))
+		session.Flash("notice", "welcome")
+		ctx.String(http.StatusOK, "ok")
+	})
+	g.Get("/me", func(ctx *msgo.Context) {
+		session := ctx.Session()
+		notice := ""
+		if flashes := session.Flashes("notice"); len(flashes) > 0 {
+			notice = flashes[0].(string)
+		}
+		ctx.String(http.StatusOK, "%s|%s", session.GetString("user"), notice)
+	})
+	g.Get("/logout", func(ctx *msgo.Context) {
+		ctx.Session().Destroy()
+		ctx.String(http.StatusOK, "bye")
+	})
+	return &client{engine: engine, cookies: make(map[string]*http.Cookie)}
+}
+
+// revocable 服务端存储可以让旧的 cookie 失效 CookieStore 不能
+func testLoginFlow(t *testing.T, config msgo.SessionConfig, revocable bool) {
+	c := newClient(config)
+	if w := c.get(t, "/user/me"); w.Body.String() != "|" || w.Header().Get("Set-Cookie") != "" {
+		t.Fatalf("anonymous = %q %v", w.Body, w.Header())
+	}
+	c.get(t, "/user/login?name=dema")
+	first := c.cookies["msgo_session"]
+	if first == nil || !first.HttpOnly || first.MaxAge <= 0 {
+		t.Fatalf("cookie = %+v", first)
+	}
+	if w := c.get(t, "/user/me"); w.Body.String() != "dema|welcome" {
+		t.Fatalf("me = %q", w.Body)
+	}
+	// flash 只能读取一次
+	if w := c.get(t, "/user/me"); w.Body.String() != "dema|" {
+		t.Fatalf("me = %q", w.Body)
+	}
+	// 再次登录更换 id 旧的 cookie 失效
+	c.get(t, "/user/login?name=other")
+	if c.cookies["msgo_session"].Value == first.Value {
+		t.Fatal("session id was not regenerated")
+	}
+	stale := &client{engine: c.engine, cookies: map[string]*http.Cookie{"msgo_session": first}}
+	if w := stale.get(t, "/user/me"); revocable && w.Body.String() != "|" {
+		t.Fatalf("stale session = %q", w.Body)
+	}
+	c.get(t, "/user/logout")
+	if c.cookies["msgo_session"] != nil {
+		t.Fatal("cookie not removed on logout")
+	}
+	if w := c.get(t, "/user/me"); w.Body.String() != "|" {
+		t.Fatalf("after logout = %q", w.Body)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := sessions.NewMemoryStore(0)
+	defer store.Close()
+	testLoginFlow(t, msgo.SessionConfig{Store: store}, true)
+}
+
+func TestCookieStore(t *testing.T) {
+	store, err := sessions.NewCookieStore(bytes.Repeat([]byte("k"), 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testLoginFlow(t, msgo.SessionConfig{Store: store}, false)
+
+	c := newClient(msgo.SessionConfig{Store: store})
+	c.get(t, "/user/login?name=dema")
+	cookie := c.cookies["msgo_session"]
+	if strings.Contains(cookie.Value, "dema") {
+		t.Fatal("cookie store leaks session values")
+	}
+	// 修改中间的字符 最后一个字符可能只包含不参与解码的填充位
+	tampered := []byte(cookie.Value)
+	i := len(tampered) / 2
+	if tampered[i] == 'A' {
+		tampered[i] = 'B'
+	} else {
+		tampered[i] = 'A'
+	}
+	cookie.Value = string(tampered)
+	if w := c.get(t, "/user/me"); w.Body.String() != "|" {
+		t.Fatalf("tampered = %q", w.Body)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := sessions.NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testLoginFlow(t, msgo.SessionConfig{Store: store}, true)
+
+	c := newClient(msgo.SessionConfig{Store: store})
+	c.get(t, "/user/login?name=dema")
+	// 新的 FileStore 读取同一个目录 模拟重启
+	restarted, _ := sessions.NewFileStore(dir)
+	c2 := newClient(msgo.SessionConfig{Store: restarted})
+	c2.cookies = c.cookies
+	if w := c2.get(t, "/user/me"); !strings.HasPrefix(w.Body.String(), "dema|") {
+		t.Fatalf("after restart = %q", w.Body)
+	}
+	if _, err := store.Load(context.Background(), "../../etc/passwd"); err != sessions.ErrNotFound {
+		t.Fatalf("err = %v", err)
+	}
+	// 损坏的文件当作会话不存在
+	corrupt := strings.Repeat("a", len(c.cookies["msgo_session"].Value))
+	os.WriteFile(filepath.Join(dir, corrupt+".session"), []byte("garbage"), 0o600)
+	if _, err := store.Load(context.Background(), corrupt); err != sessions.ErrNotFound {
+		t.Fatalf("corrupt err = %v", err)
+	}
+	removed, err := store.RemoveExpired(time.Now().Add(48 * time.Hour))
+	if err != nil || removed != 2 {
+		t.Fatalf("removed = %d %v", removed, err)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	c := newClient(msgo.SessionConfig{IdleTimeout: 200 * time.Millisecond, AbsoluteTimeout: time.Second})
+	c.get(t, "/user/login?name=dema")
+	// 每次请求都会延长空闲过期时间
+	for i := 0; i < 3; i++ {
+		time.Sleep(120 * time.Millisecond)
+		if w := c.get(t, "/user/me"); !strings.HasPrefix(w.Body.String(), "dema|") {
+			t.Fatalf("request %d = %q", i, w.Body)
+		}
+	}
+	time.Sleep(300 * time.Millisecond)
+	if w := c.get(t, "/user/me"); w.Body.String() != "|" {
+		t.Fatalf("idle expired = %q", w.Body)
+	}
+
+	c = newClient(msgo.SessionConfig{IdleTimeout: time.Second, AbsoluteTimeout: 60 * time.Millisecond})
+	c.get(t, "/user/login?name=dema")
+	time.Sleep(80 * time.Millisecond)
+	if w := c.get(t, "/user/me"); w.Body.String() != "|" {
+		t.Fatalf("absolute expired = %q", w.Body)
+	}
+}
@@ -0,0 +1,250 @@
+package sessions
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotFound 会话不存在 过期或者 token 无效
+var ErrNotFound = errors.New("sessions: session not found")
+
+// Store 保存会话 Redis 这样的存储可以用 record.ID 作为 key 在 record.ExpiresAt 过期
+type Store interface {
+	// Load 读取 Save 返回的 token 对应的会话 不存在时返回 ErrNotFound
+	Load(ctx context.Context, token string) (*Record, error)
+	// Save 保存会话 返回写入 cookie 的 token 服务端存储一般直接返回 record.ID
+	Save(ctx context.Context, record *Record) (string, error)
+	// Delete 删除 token 对应的会话 会话不存在时不返回错误
+	Delete(ctx context.Context, token string) error
+}
+
+func init() {
+	Register(map[string]any{})
+	Register([]any{})
+	Register(time.Time{})
+}
+
+// Register 注册保存在会话中的自定义类型 和 gob.Register 相同
+func Register(value any) {
+	gob.Register(value)
+}
+
+// EncodeRecord 使用 encoding/gob 编码会话 实现 Store 时可以使用
+func EncodeRecord(record *Record) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(record); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func DecodeRecord(data []byte) (*Record, error) {
+	record := &Record{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+type memoryEntry struct {
+	data    []byte
+	expires time.Time
+}
+
+// MemoryStore 进程内的 Store 重启后会话丢失 多个实例之间不共享
+// 保存的是编码后的数据 不同请求之间不会共享 map 等可变的值
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]memoryEntry
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// NewMemoryStore cleanupInterval 为清理过期会话的间隔 默认 1 分钟
+func NewMemoryStore(cleanupInterval time.Duration) *MemoryStore {
+	if cleanupInterval <= 0 {
+		cleanupInterval = time.Minute
+	}
+	s := &MemoryStore{
+		sessions: make(map[string]memoryEntry),
+		stopCh:   make(chan struct{}),
+	}
+	go s.cleanup(cleanupInterval)
+	return s
+}
+
+func (s *MemoryStore) Load(_ context.Context, token string) (*Record, error) {
+	s.mu.Lock()
+	entry, ok := s.sessions[token]
+	s.mu.Unlock()
+	if !ok || time.Now().After(entry.expires) {
+		return nil, ErrNotFound
+	}
+	return DecodeRecord(entry.data)
+}
+
+func (s *MemoryStore) Save(_ context.Context, record *Record) (string, error) {
+	data, err := EncodeRecord(record)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	s.sessions[record.ID] = memoryEntry{data: data, expires: record.ExpiresAt}
+	s.mu.Unlock()
+	return record.ID, nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, token string) error {
+	s.mu.Lock()
+	delete(s.sessions, token)
+	s.mu.Unlock()
+	return nil
+}
+
+// Len 当前保存的会话数量 包括还没有被清理的过期会话
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+// Close 停止清理过期会话的 goroutine
+func (s *MemoryStore) Close() {
+	s.stopOnce.Do(func() {
+		close(s.stopCh)
+	})
+}
+
+func (s *MemoryStore) cleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			for token, entry := range s.sessions {
+				if now.After(entry.expires) {
+					delete(s.sessions, token)
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+const fileSuffix = ".session"
+
+// FileStore 把每个会话保存为 dir 中的一个文件 可以在重启之后保留会话
+type FileStore struct {
+	dir string
+}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) path(id string) string {
+	return filepath.Join(s.dir, id+fileSuffix)
+}
+
+func (s *FileStore) Load(_ context.Context, token string) (*Record, error) {
+	// token 来自客户端 不是合法的 id 时不能拼接到路径中
+	if !ValidID(token) {
+		return nil, ErrNotFound
+	}
+	data, err := os.ReadFile(s.path(token))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	// 文件损坏时当作会话不存在 RemoveExpired 会删除这个文件
+	record, err := DecodeRecord(data)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	if time.Now().After(record.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+	return record, nil
+}
+
+// Save 先写入临时文件再重命名 并发的请求不会读到写了一半的会话
+func (s *FileStore) Save(_ context.Context, record *Record) (string, error) {
+	if !ValidID(record.ID) {
+		return "", errors.New("sessions: invalid session id")
+	}
+	data, err := EncodeRecord(record)
+	if err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path(record.ID))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return record.ID, nil
+}
+
+func (s *FileStore) Delete(_ context.Context, token string) error {
+	if !ValidID(token) {
+		return nil
+	}
+	err := os.Remove(s.path(token))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// RemoveExpired 删除在 now 时已经过期的会话 返回删除的数量 可以定期调用
+func (s *FileStore) RemoveExpired(now time.Time) (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), fileSuffix) {
+			continue
+		}
+		path := filepath.Join(s.dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		// 无法解码的文件也一并删除
+		if record, err := DecodeRecord(data); err == nil && !now.After(record.ExpiresAt) {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
@@ -0,0 +1,145 @@
+package msgo
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+type dirFS struct {
+	http.FileSystem
+	listDirectory bool
+}
+
+// Dir 返回一个本地目录的 http.FileSystem listDirectory 为 false 时不允许列出目录内容
+func Dir(root string, listDirectory bool) http.FileSystem {
+	return &dirFS{FileSystem: http.Dir(root), listDirectory: listDirectory}
+}
+
+// Static 把 root 目录下的文件挂载到 prefix 下 默认不列出目录
+func (r *routerGroup) Static(prefix, root string, middlewareFunc ...MiddlewareFunc) {
+	r.StaticFS(prefix, Dir(root, false), middlewareFunc...)
+}
+
+// StaticFS 和 Static 相同 只有通过 Dir(root, true) 创建的文件系统才会列出目录
+func (r *routerGroup) StaticFS(prefix string, fs http.FileSystem, middlewareFunc ...MiddlewareFunc) {
+	r.static(prefix, fs, false, middlewareFunc)
+}
+
+// StaticSPA 用于单页应用 找不到文件并且路径没有扩展名时返回 root 下的 index.html
+func (r *routerGroup) StaticSPA(prefix, root string, middlewareFunc ...MiddlewareFunc) {
+	r.static(prefix, Dir(root, false), true, middlewareFunc)
+}
+
+func (r *routerGroup) StaticFile(name, file string, middlewareFunc ...MiddlewareFunc) {
+	if strings.Contains(name, ":") || strings.Contains(name, "*") {
+		panic("URL parameters can not be used when serving a static file")
+	}
+	handler := func(ctx *Context) {
+		ctx.File(file)
+	}
+	r.Get(name, handler, middlewareFunc...)
+	r.Head(name, handler, middlewareFunc...)
+}
+
+func (r *routerGroup) static(prefix string, fs http.FileSystem, spa bool, middlewareFunc []MiddlewareFunc) {
+	if strings.Contains(prefix, ":") || strings.Contains(prefix, "*") {
+		panic("URL parameters can not be used when serving a static folder")
+	}
+	prefix = "/" + strings.Trim(prefix, "/")
+	handler := func(ctx *Context) {
+		name := strings.TrimPrefix(SubStringLast(ctx.R.URL.Path, "/"+r.name), prefix)
+		serveStatic(ctx, fs, name, spa)
+	}
+	// ** 不匹配 prefix 本身 访问 prefix 时重定向到 prefix/
+	// 需要先注册 prefix 已经存在的节点不会被标记为路由的终点
+	if prefix != "/" {
+		r.Get(prefix, handler, middlewareFunc...)
+		r.Head(prefix, handler, middlewareFunc...)
+	}
+	pattern := path.Join(prefix, "**")
+	r.Get(pattern, handler, middlewareFunc...)
+	r.Head(pattern, handler, middlewareFunc...)
+}
+
+func serveStatic(ctx *Context, fs http.FileSystem, name string, spa bool) {
+	// 拒绝包含 .. 的路径 防止访问根目录之外的文件
+	for _, segment := range strings.Split(strings.ReplaceAll(name, "\\", "/"), "/") {
+		if segment == ".." {
+			ctx.W.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(ctx.W, "%s invalid path \n", ctx.R.RequestURI)
+			return
+		}
+	}
+	name = path.Clean("/" + name)
+	f, err := fs.Open(name)
+	if err != nil {
+		if spa && path.Ext(name) == "" {
+			serveFile(ctx, fs, "/index.html")
+			return
+		}
+		staticError(ctx, err)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		staticError(ctx, err)
+		return
+	}
+	if !info.IsDir() {
+		http.ServeContent(ctx.W, ctx.R, info.Name(), info.ModTime(), f)
+		return
+	}
+	// 目录需要以 / 结尾 否则页面中的相对路径会出错
+	if !strings.HasSuffix(ctx.R.URL.Path, "/") {
+		target := ctx.R.URL.Path + "/"
+		if ctx.R.URL.RawQuery != "" {
+			target += "?" + ctx.R.URL.RawQuery
+		}
+		http.Redirect(ctx.W, ctx.R, target, http.StatusMovedPermanently)
+		return
+	}
+	index := path.Join(name, "index.html")
+	if indexFile, err := fs.Open(index); err == nil {
+		indexFile.Close()
+		serveFile(ctx, fs, index)
+		return
+	}
+	if d, ok := fs.(*dirFS); ok && d.listDirectory {
+		ctx.FileFromFS(strings.TrimSuffix(name, "/")+"/", fs)
+		return
+	}
+	if spa {
+		serveFile(ctx, fs, "/index.html")
+		return
+	}
+	staticError(ctx, os.ErrNotExist)
+}
+
+func serveFile(ctx *Context, fs http.FileSystem, name string) {
+	f, err := fs.Open(name)
+	if err != nil {
+		staticError(ctx, err)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		staticError(ctx, os.ErrNotExist)
+		return
+	}
+	http.ServeContent(ctx.W, ctx.R, info.Name(), info.ModTime(), f)
+}
+
+func staticError(ctx *Context, err error) {
+	if os.IsPermission(err) {
+		ctx.W.WriteHeader(http.StatusForbidden)
+		fmt.Fprintf(ctx.W, "%s forbidden \n", ctx.R.RequestURI)
+		return
+	}
+	ctx.W.WriteHeader(http.StatusNotFound)
+	fmt.Fprintf(ctx.W, "%s not found \n", ctx.R.RequestURI)
+}
@@ -0,0 +1,90 @@
+package msgo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func staticEngine(t *testing.T) *Engine {
+	t.Helper()
+	root := t.TempDir()
+	// 根目录之外的文件 不能通过 .. 访问
+	os.WriteFile(filepath.Join(filepath.Dir(root), "secret.txt"), []byte("secret"), 0o644)
+	os.MkdirAll(filepath.Join(root, "docs"), 0o755)
+	os.MkdirAll(filepath.Join(root, "site"), 0o755)
+	os.WriteFile(filepath.Join(root, "hello.txt"), []byte("hello"), 0o644)
+	os.WriteFile(filepath.Join(root, "docs", "a.txt"), []byte("a"), 0o644)
+	os.WriteFile(filepath.Join(root, "site", "index.html"), []byte("<app>"), 0o644)
+	os.WriteFile(filepath.Join(root, "site", "app.js"), []byte("js"), 0o644)
+
+	engine := New()
+	g := engine.Group("user")
+	g.Static("/static", root)
+	g.StaticFS("/files", Dir(root, true))
+	g.StaticSPA("/app", filepath.Join(root, "site"))
+	return engine
+}
+
+func staticGet(engine *Engine, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	return w
+}
+
+func TestStatic(t *testing.T) {
+	engine := staticEngine(t)
+	if w := staticGet(engine, "/user/static/hello.txt"); w.Code != http.StatusOK || w.Body.String() != "hello" {
+		t.Fatalf("file = %d %q", w.Code, w.Body)
+	}
+	if w := staticGet(engine, "/user/static"); w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/user/static/" {
+		t.Fatalf("prefix = %d %v", w.Code, w.Header())
+	}
+	if w := staticGet(engine, "/user/static/docs"); w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/user/static/docs/" {
+		t.Fatalf("directory = %d %v", w.Code, w.Header())
+	}
+	// 默认不列出目录
+	if w := staticGet(engine, "/user/static/docs/"); w.Code != http.StatusNotFound {
+		t.Fatalf("listing = %d", w.Code)
+	}
+	if w := staticGet(engine, "/user/files/docs/"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "a.txt") {
+		t.Fatalf("listing = %d %q", w.Code, w.Body)
+	}
+	if w := staticGet(engine, "/user/static/missing.txt"); w.Code != http.StatusNotFound {
+		t.Fatalf("missing = %d", w.Code)
+	}
+}
+
+func TestStaticTraversal(t *testing.T) {
+	engine := staticEngine(t)
+	for _, target := range []string{
+		"/user/static/%2e%2e/secret.txt",
+		"/user/static/docs/%2e%2e/%2e%2e/secret.txt",
+		"/user/static/..%5csecret.txt",
+		"/user/static/docs%5c..%5c..%5csecret.txt",
+	} {
+		w := staticGet(engine, target)
+		if w.Code == http.StatusOK || w.Body.String() == "secret" {
+			t.Fatalf("%s = %d %q", target, w.Code, w.Body)
+		}
+	}
+}
+
+func TestStaticSPA(t *testing.T) {
+	engine := staticEngine(t)
+	if w := staticGet(engine, "/user/app/app.js"); w.Body.String() != "js" {
+		t.Fatalf("asset = %d %q", w.Code, w.Body)
+	}
+	// 前端路由返回 index.html 缺少的静态资源仍然返回 404
+	for _, target := range []string{"/user/app/", "/user/app/users/1"} {
+		if w := staticGet(engine, target); w.Code != http.StatusOK || w.Body.String() != "<app>" {
+			t.Fatalf("%s = %d %q", target, w.Code, w.Body)
+		}
+	}
+	if w := staticGet(engine, "/user/app/missing.js"); w.Code != http.StatusNotFound {
+		t.Fatalf("missing asset = %d", w.Code)
+	}
+}
@@ -0,0 +1,25 @@
+package msgo
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestTemplateCacheBounded(t *testing.T) {
+	engine := New()
+	for i := 0; i < maxTemplateCacheSize+10; i++ {
+		engine.templateSet(nil, "page"+strconv.Itoa(i), []string{"page.html"}, nil)
+	}
+	count := 0
+	engine.templateCache.Range(func(key, value any) bool {
+		count++
+		return true
+	})
+	if count != maxTemplateCacheSize {
+		t.Fatalf("cached = %d", count)
+	}
+	// 已经缓存的模板仍然返回同一个集合
+	if engine.templateSet(nil, "page0", []string{"page.html"}, nil) != engine.templateSet(nil, "page0", []string{"page.html"}, nil) {
+		t.Fatal("cached template set not reused")
+	}
+}
@@ -0,0 +1,154 @@
+package msgo
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type TimeoutConfig struct {
+	// Timeout 必须大于 0
+	Timeout time.Duration
+	// StatusCode 超时后返回的状态码 默认 503 作为网关转发请求时可以使用 504
+	StatusCode int
+	// Response 超时后调用 自定义超时的响应 设置后忽略 StatusCode
+	Response HandleFunc
+}
+
+// Timeout 为请求设置截止时间 handler 在新的 goroutine 中执行 写入的内容先缓冲
+// 截止时间前完成时输出缓冲的响应 否则返回超时响应 之后 handler 的写入返回 http.ErrHandlerTimeout
+// handler 需要检查 ctx.Done() 及时退出 handler 通过 Set 保存的数据在完成后合并回外层的 Context
+// 响应会被完整缓冲 Flush 不起作用 Stream 和 SSEvent 这样的流式响应不能放在 Timeout 之后
+func Timeout(config TimeoutConfig) MiddlewareFunc {
+	if config.Timeout <= 0 {
+		panic("msgo: TimeoutConfig.Timeout must be positive")
+	}
+	if config.StatusCode == 0 {
+		config.StatusCode = http.StatusServiceUnavailable
+	}
+	if config.Response == nil {
+		config.Response = func(ctx *Context) {
+			ctx.W.WriteHeader(config.StatusCode)
+			fmt.Fprintf(ctx.W, "%s timeout \n", ctx.R.RequestURI)
+		}
+	}
+	return func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			c, cancel := context.WithTimeout(ctx.R.Context(), config.Timeout)
+			defer cancel()
+
+			tw := &timeoutWriter{w: ctx.W, header: make(http.Header)}
+			// handler 可能在超时之后继续运行 不能使用会被放回 pool 的 ctx
+			tctx := ctx.detach(tw, ctx.R.WithContext(c))
+			done := make(chan struct{})
+			panicCh := make(chan any, 1)
+			go func() {
+				defer func() {
+					if e := recover(); e != nil {
+						panicCh <- e
+					}
+				}()
+				next(tctx)
+				tw.mu.Lock()
+				// 超时后没有人会使用 tctx 由这里清理 multipart 临时文件
+				if tw.timedOut {
+					removeMultipart(tctx.R)
+				}
+				tw.finished = true
+				tw.mu.Unlock()
+				close(done)
+			}()
+
+			select {
+			case e := <-panicCh:
+				panic(e)
+			case <-done:
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				header := ctx.W.Header()
+				for k, v := range tw.header {
+					header[k] = v
+				}
+				if tw.status == 0 {
+					tw.status = http.StatusOK
+				}
+				tctx.mu.RLock()
+				if len(tctx.keys) > 0 {
+					ctx.mu.Lock()
+					if ctx.keys == nil {
+						ctx.keys = make(map[string]any, len(tctx.keys))
+					}
+					for k, v := range tctx.keys {
+						ctx.keys[k] = v
+					}
+					ctx.mu.Unlock()
+				}
+				tctx.mu.RUnlock()
+				// 把解析出的 multipart 表单交还给外层请求 由 ServeHTTP 统一清理
+				if ctx.R.MultipartForm == nil {
+					ctx.R.MultipartForm = tctx.R.MultipartForm
+				}
+				ctx.W.WriteHeader(tw.status)
+				_, _ = ctx.W.Write(tw.buf.Bytes())
+			case <-c.Done():
+				tw.mu.Lock()
+				tw.timedOut = true
+				// handler 恰好已经结束 但是 select 选择了超时分支
+				if tw.finished {
+					removeMultipart(tctx.R)
+				}
+				tw.mu.Unlock()
+				if c.Err() == context.DeadlineExceeded {
+					config.Response(ctx)
+				}
+				// 客户端断开时不需要响应
+			}
+		}
+	}
+}
+
+// timeoutWriter 缓冲 handler 写入的响应 超时后拒绝所有写入
+type timeoutWriter struct {
+	w      http.ResponseWriter
+	header http.Header
+
+	mu       sync.Mutex
+	buf      bytes.Buffer
+	status   int
+	timedOut bool
+	finished bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	return tw.buf.Write(b)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.status != 0 {
+		return
+	}
+	tw.status = code
+}
+
+func removeMultipart(r *http.Request) {
+	if r.MultipartForm != nil {
+		_ = r.MultipartForm.RemoveAll()
+	}
+}
@@ -0,0 +1,64 @@
+package msgo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	engine := New()
+	g := engine.Group("user")
+	lateWrite := make(chan error, 1)
+	g.Use(Timeout(TimeoutConfig{Timeout: 20 * time.Millisecond, StatusCode: http.StatusGatewayTimeout}))
+	g.Get("/fast", func(ctx *Context) {
+		ctx.W.Header().Set("X-Handler", "fast")
+		_ = ctx.String(http.StatusCreated, "done")
+	})
+	g.Get("/slow", func(ctx *Context) {
+		<-ctx.Done()
+		// 等超时响应写完之后再写入
+		time.Sleep(10 * time.Millisecond)
+		_, err := ctx.W.Write([]byte("too late"))
+		lateWrite <- err
+	})
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/user/fast", nil))
+	if w.Code != http.StatusCreated || w.Body.String() != "done" || w.Header().Get("X-Handler") != "fast" {
+		t.Fatalf("fast: %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/user/slow", nil))
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("slow: %d", w.Code)
+	}
+	if err := <-lateWrite; err != http.ErrHandlerTimeout {
+		t.Fatalf("late write err = %v", err)
+	}
+	if body := w.Body.String(); body != "/user/slow timeout \n" {
+		t.Fatalf("body = %q", body)
+	}
+}
+
+func TestTimeoutKeepsKeys(t *testing.T) {
+	engine := New()
+	var user string
+	// Timeout 外层的中间件在 handler 完成后读取它保存的数据
+	outer := func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			ctx.Set("trace", "outer")
+			next(ctx)
+			user = ctx.GetString("user") + "|" + ctx.GetString("trace")
+		}
+	}
+	engine.Group("user").Get("/info", func(ctx *Context) {
+		ctx.Set("user", "dema")
+	}, Timeout(TimeoutConfig{Timeout: time.Second}), outer)
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/user/info", nil))
+	if user != "dema|outer" {
+		t.Fatalf("keys = %q", user)
+	}
+}
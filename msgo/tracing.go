@@ -0,0 +1,101 @@
+package msgo
+
+import (
+	"context"
+	"github.com/demo-go/msgo/tracing"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// traceServer 为整个请求创建 SERVER span 上游通过 traceparent 传来的信息作为父节点
+func traceServer(tracer *tracing.Tracer, next HandleFunc) HandleFunc {
+	return func(ctx *Context) {
+		parent := tracing.Extract(ctx.R.Context(), ctx.R.Header)
+		// 使用注册的路由作为名称 避免路径参数导致 span 名称过多
+		c, span := tracer.Start(parent, ctx.R.Method+" "+ctx.FullPath(), tracing.SpanKindServer)
+		defer span.End()
+		span.SetAttribute("http.method", ctx.R.Method)
+		span.SetAttribute("http.route", ctx.FullPath())
+		span.SetAttribute("http.target", ctx.R.URL.RequestURI())
+		span.SetAttribute("http.host", ctx.R.Host)
+		span.SetAttribute("http.user_agent", ctx.R.UserAgent())
+
+		sc := span.SpanContext()
+		ctx.W.Header().Set(HeaderTraceParent, tracing.TraceParent(sc))
+		w := newResponseWriter(ctx.W)
+		ctx.W = w
+		c = context.WithValue(c, traceContextKey{}, traceContextFromSpan(span))
+		ctx.R = ctx.R.WithContext(c)
+		next(ctx)
+		ctx.W = w.ResponseWriter
+
+		status := w.Status()
+		span.SetAttribute("http.status_code", status)
+		span.SetAttribute("http.response_size", w.Size())
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(tracing.StatusError, http.StatusText(status))
+		}
+	}
+}
+
+func traceMiddleware(tracer *tracing.Tracer, middlewareFunc MiddlewareFunc, next HandleFunc) HandleFunc {
+	h := middlewareFunc(next)
+	if tracer == nil {
+		return h
+	}
+	return traceHandle(tracer, "middleware "+funcName(middlewareFunc), h)
+}
+
+// traceHandle 在 h 执行期间记录一个子 span 结束后恢复原来的请求 context
+func traceHandle(tracer *tracing.Tracer, name string, h HandleFunc) HandleFunc {
+	return func(ctx *Context) {
+		r := ctx.R
+		c, span := tracer.Start(r.Context(), name, tracing.SpanKindInternal)
+		defer span.End()
+		ctx.R = r.WithContext(c)
+		h(ctx)
+		// h 解析的 multipart 表单交还给原来的请求 由 ServeHTTP 清理临时文件
+		if r.MultipartForm == nil {
+			r.MultipartForm = ctx.R.MultipartForm
+		}
+		ctx.R = r
+	}
+}
+
+func funcName(f any) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(f).Pointer())
+	if fn == nil {
+		return "unknown"
+	}
+	name := fn.Name()
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// startSpan 在当前请求的 span 下创建子 span 没有设置 Tracer 时返回 nil nil 的 span 可以安全调用
+func (c *Context) startSpan(name string) *tracing.Span {
+	if c.engine == nil || c.engine.Tracer == nil {
+		return nil
+	}
+	_, span := c.engine.Tracer.Start(c.R.Context(), name, tracing.SpanKindInternal)
+	return span
+}
+
+// traceContextFromSpan 把 tracing 的 span 转换为 RequestID 中间件使用的 TraceContext
+func traceContextFromSpan(span *tracing.Span) TraceContext {
+	sc := span.SpanContext()
+	trace := TraceContext{
+		TraceID:    sc.TraceID.String(),
+		SpanID:     sc.SpanID.String(),
+		Sampled:    sc.Sampled,
+		TraceState: sc.TraceState,
+	}
+	if parent := span.ParentID(); parent.IsValid() {
+		trace.ParentID = parent.String()
+	}
+	return trace
+}
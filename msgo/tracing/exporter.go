@@ -0,0 +1,141 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+type Exporter interface {
+	ExportSpans(ctx context.Context, spans []SpanData) error
+	Shutdown(ctx context.Context) error
+}
+
+// StdoutExporter 每个 span 输出一行 JSON
+type StdoutExporter struct {
+	// Writer 默认 os.Stdout
+	Writer io.Writer
+	mu     sync.Mutex
+}
+
+func (e *StdoutExporter) ExportSpans(ctx context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	w := e.Writer
+	if w == nil {
+		w = os.Stdout
+	}
+	encoder := json.NewEncoder(w)
+	for _, span := range spans {
+		if err := encoder.Encode(span); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *StdoutExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// ZipkinExporter 以 Zipkin v2 JSON 格式发送 span Jaeger 也可以接收这种格式
+// Endpoint 例如 http://localhost:9411/api/v2/spans
+type ZipkinExporter struct {
+	Endpoint string
+	// Client 默认使用 10 秒超时的 http.Client
+	Client *http.Client
+}
+
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName"`
+}
+
+type zipkinAnnotation struct {
+	Timestamp int64  `json:"timestamp"`
+	Value     string `json:"value"`
+}
+
+type zipkinSpan struct {
+	TraceID       string             `json:"traceId"`
+	ID            string             `json:"id"`
+	ParentID      string             `json:"parentId,omitempty"`
+	Name          string             `json:"name"`
+	Kind          string             `json:"kind,omitempty"`
+	Timestamp     int64              `json:"timestamp"`
+	Duration      int64              `json:"duration"`
+	LocalEndpoint zipkinEndpoint     `json:"localEndpoint"`
+	Tags          map[string]string  `json:"tags,omitempty"`
+	Annotations   []zipkinAnnotation `json:"annotations,omitempty"`
+}
+
+func (e *ZipkinExporter) ExportSpans(ctx context.Context, spans []SpanData) error {
+	body, err := json.Marshal(toZipkin(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := e.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("zipkin exporter: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+func (e *ZipkinExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func toZipkin(spans []SpanData) []zipkinSpan {
+	out := make([]zipkinSpan, 0, len(spans))
+	for _, span := range spans {
+		zs := zipkinSpan{
+			TraceID:       span.TraceID,
+			ID:            span.SpanID,
+			ParentID:      span.ParentID,
+			Name:          span.Name,
+			Timestamp:     span.Start.UnixNano() / int64(time.Microsecond),
+			Duration:      int64(span.Duration() / time.Microsecond),
+			LocalEndpoint: zipkinEndpoint{ServiceName: span.ServiceName},
+		}
+		// zipkin 没有 INTERNAL 类型 不设置即可
+		if span.Kind != SpanKindInternal.String() {
+			zs.Kind = span.Kind
+		}
+		if len(span.Attributes) > 0 || span.StatusCode == StatusError {
+			zs.Tags = make(map[string]string, len(span.Attributes)+1)
+			for k, v := range span.Attributes {
+				zs.Tags[k] = fmt.Sprint(v)
+			}
+			if span.StatusCode == StatusError {
+				zs.Tags["error"] = span.StatusMessage
+			}
+		}
+		for _, event := range span.Events {
+			zs.Annotations = append(zs.Annotations, zipkinAnnotation{
+				Timestamp: event.Time.UnixNano() / int64(time.Microsecond),
+				Value:     event.Name,
+			})
+		}
+		out = append(out, zs)
+	}
+	return out
+}
@@ -0,0 +1,84 @@
+package tracing
+
+import (
+	"context"
+	"github.com/demo-go/msgo/internal/tracectx"
+	"net/http"
+	"strconv"
+)
+
+const (
+	traceParentHeader = "traceparent"
+	traceStateHeader  = "tracestate"
+)
+
+// Extract 从请求头中解析 traceparent 和 tracestate 保存到 ctx 中
+func Extract(ctx context.Context, header http.Header) context.Context {
+	tp, ok := tracectx.Parse(header.Get(traceParentHeader))
+	if !ok {
+		return ctx
+	}
+	sc := SpanContext{
+		TraceID: tp.TraceID,
+		SpanID:  tp.ParentID,
+		Sampled: tp.Sampled(),
+	}
+	if state := header.Get(traceStateHeader); tracectx.ValidTraceState(state) {
+		sc.TraceState = state
+	}
+	return ContextWithRemoteSpanContext(ctx, sc)
+}
+
+// Inject 把 ctx 中当前 span 的信息写入请求头
+func Inject(ctx context.Context, header http.Header) {
+	sc := SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+	header.Set(traceParentHeader, TraceParent(sc))
+	if sc.TraceState != "" {
+		header.Set(traceStateHeader, sc.TraceState)
+	}
+}
+
+func TraceParent(sc SpanContext) string {
+	tp := tracectx.TraceParent{
+		TraceID:  sc.TraceID,
+		ParentID: sc.SpanID,
+	}
+	if sc.Sampled {
+		tp.Flags = tracectx.FlagSampled
+	}
+	return tp.String()
+}
+
+// Transport 为每个发出的请求创建一个 CLIENT span 并传递 traceparent
+type Transport struct {
+	Tracer *Tracer
+	Base   http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	ctx, span := t.Tracer.Start(req.Context(), "HTTP "+req.Method, SpanKindClient)
+	defer span.End()
+	span.SetAttribute("http.method", req.Method)
+	span.SetAttribute("http.url", req.URL.Redacted())
+	span.SetAttribute("net.peer.name", req.URL.Hostname())
+
+	req = req.Clone(ctx)
+	Inject(ctx, req.Header)
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	span.SetAttribute("http.status_code", resp.StatusCode)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(StatusError, "HTTP "+strconv.Itoa(resp.StatusCode))
+	}
+	return resp, nil
+}
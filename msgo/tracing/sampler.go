@@ -0,0 +1,87 @@
+package tracing
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+type SamplingParameters struct {
+	Parent  SpanContext
+	TraceID TraceID
+	Name    string
+	Kind    SpanKind
+}
+
+// Sampler 决定一个新的 span 是否需要被记录和导出
+type Sampler interface {
+	ShouldSample(p SamplingParameters) bool
+	Description() string
+}
+
+type alwaysSampler struct{}
+
+func (alwaysSampler) ShouldSample(SamplingParameters) bool { return true }
+
+func (alwaysSampler) Description() string { return "AlwaysOnSampler" }
+
+type neverSampler struct{}
+
+func (neverSampler) ShouldSample(SamplingParameters) bool { return false }
+
+func (neverSampler) Description() string { return "AlwaysOffSampler" }
+
+func AlwaysSample() Sampler {
+	return alwaysSampler{}
+}
+
+func NeverSample() Sampler {
+	return neverSampler{}
+}
+
+type ratioSampler struct {
+	fraction   float64
+	upperBound uint64
+}
+
+// TraceIDRatioBased 按 trace id 采样 同一个 trace 在不同服务中的结果一致
+func TraceIDRatioBased(fraction float64) Sampler {
+	if fraction >= 1 {
+		return AlwaysSample()
+	}
+	if fraction <= 0 {
+		fraction = 0
+	}
+	return &ratioSampler{
+		fraction:   fraction,
+		upperBound: uint64(fraction * (1 << 63)),
+	}
+}
+
+func (s *ratioSampler) ShouldSample(p SamplingParameters) bool {
+	x := binary.BigEndian.Uint64(p.TraceID[8:16]) >> 1
+	return x < s.upperBound
+}
+
+func (s *ratioSampler) Description() string {
+	return fmt.Sprintf("TraceIDRatioBased{%g}", s.fraction)
+}
+
+type parentBasedSampler struct {
+	root Sampler
+}
+
+// ParentBased 有父节点时沿用父节点的采样结果 没有父节点时交给 root 决定
+func ParentBased(root Sampler) Sampler {
+	return &parentBasedSampler{root: root}
+}
+
+func (s *parentBasedSampler) ShouldSample(p SamplingParameters) bool {
+	if p.Parent.IsValid() {
+		return p.Parent.Sampled
+	}
+	return s.root.ShouldSample(p)
+}
+
+func (s *parentBasedSampler) Description() string {
+	return "ParentBased{root:" + s.root.Description() + "}"
+}
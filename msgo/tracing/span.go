@@ -0,0 +1,254 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type TraceID [16]byte
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+type SpanID [8]byte
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+// SpanContext 需要在服务之间传递的 span 信息
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Sampled    bool
+	TraceState string
+	// Remote 为 true 表示是从请求头中解析出来的 由上游服务创建
+	Remote bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = iota
+	SpanKindServer
+	SpanKindClient
+)
+
+func (k SpanKind) String() string {
+	switch k {
+	case SpanKindServer:
+		return "SERVER"
+	case SpanKindClient:
+		return "CLIENT"
+	default:
+		return "INTERNAL"
+	}
+}
+
+type StatusCode int
+
+const (
+	StatusUnset StatusCode = iota
+	StatusOK
+	StatusError
+)
+
+type Event struct {
+	Name       string         `json:"name"`
+	Time       time.Time      `json:"time"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+// SpanData 结束后的 span 快照 交给 Exporter 导出
+type SpanData struct {
+	ServiceName   string         `json:"serviceName"`
+	Name          string         `json:"name"`
+	Kind          string         `json:"kind"`
+	TraceID       string         `json:"traceId"`
+	SpanID        string         `json:"spanId"`
+	ParentID      string         `json:"parentId,omitempty"`
+	Start         time.Time      `json:"start"`
+	End           time.Time      `json:"end"`
+	Attributes    map[string]any `json:"attributes,omitempty"`
+	Events        []Event        `json:"events,omitempty"`
+	StatusCode    StatusCode     `json:"statusCode"`
+	StatusMessage string         `json:"statusMessage,omitempty"`
+}
+
+func (d SpanData) Duration() time.Duration {
+	return d.End.Sub(d.Start)
+}
+
+type Span struct {
+	tracer      *Tracer
+	spanContext SpanContext
+	parentID    SpanID
+	kind        SpanKind
+
+	mu            sync.Mutex
+	name          string
+	start         time.Time
+	end           time.Time
+	attributes    map[string]any
+	events        []Event
+	statusCode    StatusCode
+	statusMessage string
+	ended         bool
+}
+
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.spanContext
+}
+
+// ParentID 根节点返回无效的 SpanID
+func (s *Span) ParentID() SpanID {
+	if s == nil {
+		return SpanID{}
+	}
+	return s.parentID
+}
+
+// IsRecording 没有被采样的 span 只用于传递 trace id 不记录任何数据
+func (s *Span) IsRecording() bool {
+	return s != nil && s.spanContext.Sampled
+}
+
+func (s *Span) SetName(name string) {
+	if !s.IsRecording() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.name = name
+}
+
+func (s *Span) SetAttribute(key string, value any) {
+	if !s.IsRecording() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.attributes == nil {
+		s.attributes = make(map[string]any)
+	}
+	s.attributes[key] = value
+}
+
+func (s *Span) AddEvent(name string, attributes map[string]any) {
+	if !s.IsRecording() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, Event{Name: name, Time: time.Now(), Attributes: attributes})
+}
+
+func (s *Span) SetStatus(code StatusCode, message string) {
+	if !s.IsRecording() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statusCode = code
+	s.statusMessage = message
+}
+
+// RecordError 记录错误事件并把状态设置为错误
+func (s *Span) RecordError(err error) {
+	if err == nil || !s.IsRecording() {
+		return
+	}
+	s.AddEvent("exception", map[string]any{
+		"exception.type":    fmt.Sprintf("%T", err),
+		"exception.message": err.Error(),
+	})
+	s.SetStatus(StatusError, err.Error())
+}
+
+func (s *Span) End() {
+	if !s.IsRecording() {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	data := s.snapshot()
+	s.mu.Unlock()
+	s.tracer.export(data)
+}
+
+func (s *Span) snapshot() SpanData {
+	data := SpanData{
+		ServiceName:   s.tracer.serviceName,
+		Name:          s.name,
+		Kind:          s.kind.String(),
+		TraceID:       s.spanContext.TraceID.String(),
+		SpanID:        s.spanContext.SpanID.String(),
+		Start:         s.start,
+		End:           s.end,
+		Events:        s.events,
+		StatusCode:    s.statusCode,
+		StatusMessage: s.statusMessage,
+	}
+	if s.parentID.IsValid() {
+		data.ParentID = s.parentID.String()
+	}
+	if len(s.attributes) > 0 {
+		data.Attributes = make(map[string]any, len(s.attributes))
+		for k, v := range s.attributes {
+			data.Attributes[k] = v
+		}
+	}
+	return data
+}
+
+type spanKey struct{}
+
+type remoteSpanContextKey struct{}
+
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// ContextWithRemoteSpanContext 保存上游服务传来的 SpanContext 作为下一个 span 的父节点
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	sc.Remote = true
+	return context.WithValue(ctx, remoteSpanContextKey{}, sc)
+}
+
+// SpanContextFromContext 优先返回 ctx 中当前 span 的信息 其次是上游传来的信息
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.SpanContext()
+	}
+	sc, _ := ctx.Value(remoteSpanContextKey{}).(SpanContext)
+	return sc
+}
@@ -0,0 +1,192 @@
+package tracing
+
+import (
+	"context"
+	"github.com/demo-go/msgo/internal/tracectx"
+	"log"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	ServiceName string
+	// Sampler 默认 ParentBased(AlwaysSample())
+	Sampler Sampler
+	// Exporter 为空时 span 不会被导出
+	Exporter Exporter
+	// BatchSize 缓冲的 span 达到这个数量时导出 默认 128
+	BatchSize int
+	// FlushInterval 定时导出的间隔 默认 5 秒
+	FlushInterval time.Duration
+	// MaxQueueSize 等待导出的 span 上限 超过后丢弃 默认 2048
+	MaxQueueSize int
+}
+
+type Tracer struct {
+	serviceName string
+	sampler     Sampler
+	exporter    Exporter
+	batchSize   int
+
+	mu      sync.Mutex
+	queue   []SpanData
+	maxSize int
+	dropped int
+
+	flushCh  chan chan struct{}
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func NewTracer(config Config) *Tracer {
+	if config.Sampler == nil {
+		config.Sampler = ParentBased(AlwaysSample())
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 128
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = 5 * time.Second
+	}
+	if config.MaxQueueSize <= 0 {
+		config.MaxQueueSize = 2048
+	}
+	t := &Tracer{
+		serviceName: config.ServiceName,
+		sampler:     config.Sampler,
+		exporter:    config.Exporter,
+		batchSize:   config.BatchSize,
+		maxSize:     config.MaxQueueSize,
+		flushCh:     make(chan chan struct{}),
+		stopCh:      make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go t.loop(config.FlushInterval)
+	return t
+}
+
+// Start 创建一个新的 span 父节点从 ctx 中获取 返回的 context 中保存了新的 span
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	parent := SpanContextFromContext(ctx)
+	span := &Span{
+		tracer: t,
+		kind:   kind,
+		name:   name,
+		start:  time.Now(),
+	}
+	if parent.IsValid() {
+		span.spanContext.TraceID = parent.TraceID
+		span.spanContext.TraceState = parent.TraceState
+		span.parentID = parent.SpanID
+	} else {
+		span.spanContext.TraceID = tracectx.NewTraceID()
+	}
+	span.spanContext.SpanID = tracectx.NewSpanID()
+	span.spanContext.Sampled = t.sampler.ShouldSample(SamplingParameters{
+		Parent:  parent,
+		TraceID: span.spanContext.TraceID,
+		Name:    name,
+		Kind:    kind,
+	})
+	return ContextWithSpan(ctx, span), span
+}
+
+func (t *Tracer) ServiceName() string {
+	return t.serviceName
+}
+
+func (t *Tracer) export(data SpanData) {
+	if t.exporter == nil {
+		return
+	}
+	t.mu.Lock()
+	if len(t.queue) >= t.maxSize {
+		t.dropped++
+		t.mu.Unlock()
+		return
+	}
+	t.queue = append(t.queue, data)
+	full := len(t.queue) >= t.batchSize
+	t.mu.Unlock()
+	if full {
+		select {
+		case t.flushCh <- nil:
+		default:
+		}
+	}
+}
+
+func (t *Tracer) loop(interval time.Duration) {
+	defer close(t.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			t.exportQueued()
+		case ack := <-t.flushCh:
+			t.exportQueued()
+			if ack != nil {
+				close(ack)
+			}
+		case <-t.stopCh:
+			t.exportQueued()
+			return
+		}
+	}
+}
+
+func (t *Tracer) exportQueued() {
+	t.mu.Lock()
+	spans := t.queue
+	t.queue = nil
+	dropped := t.dropped
+	t.dropped = 0
+	t.mu.Unlock()
+	if dropped > 0 {
+		log.Printf("tracing: queue is full, dropped %d spans", dropped)
+	}
+	if len(spans) == 0 || t.exporter == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := t.exporter.ExportSpans(ctx, spans); err != nil {
+		log.Printf("tracing: export %d spans: %v", len(spans), err)
+	}
+}
+
+// Flush 立即导出所有已经结束的 span
+func (t *Tracer) Flush(ctx context.Context) error {
+	ack := make(chan struct{})
+	select {
+	case t.flushCh <- ack:
+	case <-t.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-ack:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown 导出剩余的 span 并关闭 Exporter
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	t.stopOnce.Do(func() {
+		close(t.stopCh)
+	})
+	select {
+	case <-t.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if t.exporter != nil {
+		return t.exporter.Shutdown(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestZipkinExporter(t *testing.T) {
+	received := make(chan []zipkinSpan, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		var spans []zipkinSpan
+		if err := json.NewDecoder(r.Body).Decode(&spans); err != nil {
+			t.Error(err)
+		}
+		received <- spans
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	tracer := NewTracer(Config{
+		ServiceName:   "test-service",
+		Exporter:      &ZipkinExporter{Endpoint: server.URL},
+		FlushInterval: time.Hour,
+	})
+	ctx, root := tracer.Start(context.Background(), "GET /user", SpanKindServer)
+	root.SetAttribute("http.status_code", 500)
+	_, child := tracer.Start(ctx, "db query", SpanKindInternal)
+	child.RecordError(errors.New("connection refused"))
+	child.End()
+	root.End()
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var spans []zipkinSpan
+	select {
+	case spans = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("spans not exported")
+	}
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans", len(spans))
+	}
+	db, serverSpan := spans[0], spans[1]
+	if serverSpan.Kind != "SERVER" || serverSpan.LocalEndpoint.ServiceName != "test-service" {
+		t.Fatalf("server span = %+v", serverSpan)
+	}
+	if serverSpan.Tags["http.status_code"] != "500" {
+		t.Fatalf("tags = %v", serverSpan.Tags)
+	}
+	if db.TraceID != serverSpan.TraceID || db.ParentID != serverSpan.ID || db.Kind != "" {
+		t.Fatalf("child span = %+v", db)
+	}
+	if db.Tags["error"] != "connection refused" || len(db.Annotations) != 1 {
+		t.Fatalf("child span = %+v", db)
+	}
+}
+
+func TestSampler(t *testing.T) {
+	low := TraceID{8: 0x00}
+	high := TraceID{8: 0xff}
+	sampler := TraceIDRatioBased(0.5)
+	if !sampler.ShouldSample(SamplingParameters{TraceID: low}) {
+		t.Fatal("low trace id should be sampled")
+	}
+	if sampler.ShouldSample(SamplingParameters{TraceID: high}) {
+		t.Fatal("high trace id should not be sampled")
+	}
+
+	parentBased := ParentBased(NeverSample())
+	parent := SpanContext{TraceID: high, SpanID: SpanID{1}, Sampled: true}
+	if !parentBased.ShouldSample(SamplingParameters{Parent: parent, TraceID: high}) {
+		t.Fatal("sampled parent should be followed")
+	}
+	if parentBased.ShouldSample(SamplingParameters{TraceID: low}) {
+		t.Fatal("root span should use root sampler")
+	}
+
+	tracer := NewTracer(Config{Sampler: NeverSample()})
+	defer tracer.Shutdown(context.Background())
+	_, span := tracer.Start(context.Background(), "ignored", SpanKindInternal)
+	if span.IsRecording() || !span.SpanContext().IsValid() {
+		t.Fatal("unsampled span should carry ids without recording")
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	header := http.Header{}
+	header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	header.Set("tracestate", "congo=t61rcWkgMzE")
+	ctx := Extract(context.Background(), header)
+
+	tracer := NewTracer(Config{})
+	defer tracer.Shutdown(context.Background())
+	ctx, span := tracer.Start(ctx, "handler", SpanKindServer)
+	sc := span.SpanContext()
+	if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || span.ParentID().String() != "00f067aa0ba902b7" {
+		t.Fatalf("span context = %+v", sc)
+	}
+
+	out := http.Header{}
+	Inject(ctx, out)
+	want := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + sc.SpanID.String() + "-01"
+	if got := out.Get("traceparent"); got != want {
+		t.Fatalf("traceparent = %q want %q", got, want)
+	}
+	if out.Get("tracestate") != "congo=t61rcWkgMzE" {
+		t.Fatalf("tracestate = %q", out.Get("tracestate"))
+	}
+}
@@ -14,10 +14,12 @@ type treeNode struct {
 func (t *treeNode) Put(path string) {
 	root := t
 	strs := strings.Split(path, "/")
+	routerName := ""
 	for index, name := range strs {
 		if index == 0 {
 			continue
 		}
+		routerName += "/" + name
 		children := t.children
 		isMatch := false
 		for _, node := range children {
@@ -33,9 +35,10 @@ func (t *treeNode) Put(path string) {
 				isEnd = true
 			}
 			node := &treeNode{
-				name:     name,
-				children: make([]*treeNode, 0),
-				isEnd:    isEnd,
+				name:       name,
+				children:   make([]*treeNode, 0),
+				routerName: routerName,
+				isEnd:      isEnd,
 			}
 			children = append(children, node)
 			t.children = children
@@ -46,10 +49,10 @@ func (t *treeNode) Put(path string) {
 }
 
 // get path: /get/1
+// routerName 在 Put 时已经设置 Get 会被多个请求并发调用 不能修改节点
 
 func (t *treeNode) Get(path string) *treeNode {
 	strs := strings.Split(path, "/")
-	routerName := ""
 	for index, name := range strs {
 		if index == 0 {
 			continue
@@ -61,8 +64,6 @@ func (t *treeNode) Get(path string) *treeNode {
 				node.name == "*" ||
 				strings.Contains(node.name, ":") {
 				isMatch = true
-				routerName += "/" + node.name
-				node.routerName = routerName
 				t = node
 				if index == len(strs)-1 {
 					return node
@@ -73,8 +74,6 @@ func (t *treeNode) Get(path string) *treeNode {
 		if !isMatch {
 			for _, node := range children {
 				if node.name == "**" {
-					routerName += "/" + node.name
-					node.routerName = routerName
 					return node
 				}
 			}
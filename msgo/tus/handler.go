@@ -0,0 +1,369 @@
+package tus
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultExpiration = 24 * time.Hour
+	offsetContentType = "application/offset+octet-stream"
+)
+
+type Config struct {
+	Storage Storage
+	// MaxSize 单个上传的最大字节数 默认不限制
+	MaxSize int64
+	// Expiration 未完成的上传在创建后多久过期 默认 24 小时 小于 0 时不过期
+	Expiration time.Duration
+	// OnComplete 上传完成后调用 可以在这里读取或者移动数据
+	OnComplete func(r *http.Request, info Info)
+	// NewID 生成上传的 id 结果必须满足 ValidID 默认为 32 个随机的十六进制字符
+	NewID func() string
+}
+
+// Handler 处理 tus 协议的请求 Create 和 Options 挂载在上传的集合地址上
+// Head Patch Delete 挂载在集合地址加上 id 的地址上
+type Handler struct {
+	config Config
+	mu     sync.Mutex
+	locked map[string]bool
+}
+
+func New(config Config) *Handler {
+	if config.Storage == nil {
+		panic("tus: Config.Storage is required")
+	}
+	if config.Expiration == 0 {
+		config.Expiration = defaultExpiration
+	}
+	if config.NewID == nil {
+		config.NewID = randomID
+	}
+	return &Handler{config: config, locked: make(map[string]bool)}
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Options 返回服务端支持的协议版本和扩展
+func (h *Handler) Options(w http.ResponseWriter, r *http.Request) {
+	header := w.Header()
+	header.Set("Tus-Resumable", Version)
+	header.Set("Tus-Version", Version)
+	header.Set("Tus-Extension", Extensions)
+	header.Set("Tus-Checksum-Algorithm", ChecksumAlgorithms)
+	if h.config.MaxSize > 0 {
+		header.Set("Tus-Max-Size", strconv.FormatInt(h.config.MaxSize, 10))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Create 根据 Upload-Length 和 Upload-Metadata 创建上传 Location 为请求地址加上 id
+func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
+	if !h.checkVersion(w, r) {
+		return
+	}
+	if r.Header.Get("Upload-Defer-Length") != "" {
+		writeError(w, http.StatusBadRequest, "Upload-Defer-Length is not supported")
+		return
+	}
+	size, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || size < 0 {
+		writeError(w, http.StatusBadRequest, "invalid Upload-Length")
+		return
+	}
+	if h.config.MaxSize > 0 && size > h.config.MaxSize {
+		writeError(w, http.StatusRequestEntityTooLarge, ErrSizeExceeded.Error())
+		return
+	}
+	metadata, err := ParseMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	now := time.Now().UTC()
+	info := Info{
+		ID:        h.config.NewID(),
+		Size:      size,
+		Metadata:  metadata,
+		CreatedAt: now,
+	}
+	if h.config.Expiration > 0 && size > 0 {
+		info.ExpiresAt = now.Add(h.config.Expiration)
+	}
+	if err := h.config.Storage.Create(r.Context(), info); err != nil {
+		h.storageError(w, err)
+		return
+	}
+	if info.Complete() && h.config.OnComplete != nil {
+		h.config.OnComplete(r, info)
+	}
+	w.Header().Set("Location", path.Join(r.URL.Path, info.ID))
+	setExpires(w, info)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// Head 返回已经上传的字节数 客户端从 Upload-Offset 继续上传
+func (h *Handler) Head(w http.ResponseWriter, r *http.Request, id string) {
+	if !h.checkVersion(w, r) {
+		return
+	}
+	info, err := h.info(r, id)
+	if err != nil {
+		h.storageError(w, err)
+		return
+	}
+	header := w.Header()
+	header.Set("Cache-Control", "no-store")
+	header.Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	header.Set("Upload-Length", strconv.FormatInt(info.Size, 10))
+	if len(info.Metadata) > 0 {
+		header.Set("Upload-Metadata", FormatMetadata(info.Metadata))
+	}
+	setExpires(w, info)
+	w.WriteHeader(http.StatusOK)
+}
+
+// Patch 从 Upload-Offset 开始追加请求体中的数据
+// 带有 Upload-Checksum 时校验这次的数据 不一致时返回 460 并丢弃数据
+func (h *Handler) Patch(w http.ResponseWriter, r *http.Request, id string) {
+	if !h.checkVersion(w, r) {
+		return
+	}
+	if r.Header.Get("Content-Type") != offsetContentType {
+		writeError(w, http.StatusUnsupportedMediaType, "Content-Type must be "+offsetContentType)
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		writeError(w, http.StatusBadRequest, "invalid Upload-Offset")
+		return
+	}
+	hash, sum, err := parseChecksum(r.Header.Get("Upload-Checksum"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !h.lock(id) {
+		writeError(w, http.StatusLocked, "upload is locked by another request")
+		return
+	}
+	defer h.unlock(id)
+
+	info, err := h.info(r, id)
+	if err != nil {
+		h.storageError(w, err)
+		return
+	}
+	if offset != info.Offset {
+		h.storageError(w, ErrOffsetMismatch)
+		return
+	}
+	remaining := info.Size - info.Offset
+	if r.ContentLength > remaining {
+		h.storageError(w, ErrSizeExceeded)
+		return
+	}
+	body := &chunkReader{r: r.Body, remaining: remaining, hash: hash, sum: sum}
+	n, err := h.config.Storage.Append(r.Context(), id, offset, body)
+	if err != nil {
+		h.storageError(w, err)
+		return
+	}
+	info.Offset += n
+	if info.Complete() {
+		info.ExpiresAt = time.Time{}
+		if h.config.OnComplete != nil {
+			h.config.OnComplete(r, info)
+		}
+	}
+	w.Header().Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	setExpires(w, info)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Delete 删除上传 termination 扩展
+func (h *Handler) Delete(w http.ResponseWriter, r *http.Request, id string) {
+	if !h.checkVersion(w, r) {
+		return
+	}
+	if !h.lock(id) {
+		writeError(w, http.StatusLocked, "upload is locked by another request")
+		return
+	}
+	defer h.unlock(id)
+	if err := h.config.Storage.Terminate(r.Context(), id); err != nil {
+		h.storageError(w, err)
+		return
+	}
+	w.Header().Set("Tus-Resumable", Version)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// info 过期的上传当作不存在 返回 410
+func (h *Handler) info(r *http.Request, id string) (Info, error) {
+	if !ValidID(id) {
+		return Info{}, ErrNotFound
+	}
+	info, err := h.config.Storage.Info(r.Context(), id)
+	if err == nil && info.Expired(time.Now()) {
+		return info, errExpired
+	}
+	return info, err
+}
+
+var errExpired = errors.New("tus: upload expired")
+
+// checkVersion 除了 OPTIONS 之外的请求都要带有 Tus-Resumable
+func (h *Handler) checkVersion(w http.ResponseWriter, r *http.Request) bool {
+	w.Header().Set("Tus-Resumable", Version)
+	if r.Header.Get("Tus-Resumable") != Version {
+		w.Header().Set("Tus-Version", Version)
+		writeError(w, http.StatusPreconditionFailed, "unsupported Tus-Resumable version")
+		return false
+	}
+	return true
+}
+
+// lock 同一个上传同时只能有一个请求写入
+func (h *Handler) lock(id string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.locked[id] {
+		return false
+	}
+	h.locked[id] = true
+	return true
+}
+
+func (h *Handler) unlock(id string) {
+	h.mu.Lock()
+	delete(h.locked, id)
+	h.mu.Unlock()
+}
+
+func (h *Handler) storageError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, errExpired):
+		writeError(w, http.StatusGone, err.Error())
+	case errors.Is(err, ErrOffsetMismatch):
+		writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, ErrSizeExceeded):
+		writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, ErrChecksumMismatch):
+		writeError(w, StatusChecksumMismatch, err.Error())
+	default:
+		log.Println(err)
+		writeError(w, http.StatusInternalServerError, "tus: storage error")
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	fmt.Fprintln(w, message)
+}
+
+func setExpires(w http.ResponseWriter, info Info) {
+	if !info.ExpiresAt.IsZero() {
+		w.Header().Set("Upload-Expires", info.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+// parseChecksum 解析 Upload-Checksum 格式为 算法 base64(校验和)
+func parseChecksum(header string) (hash.Hash, []byte, error) {
+	if header == "" {
+		return nil, nil, nil
+	}
+	algorithm, encoded, ok := strings.Cut(header, " ")
+	if !ok {
+		return nil, nil, errors.New("tus: invalid Upload-Checksum")
+	}
+	sum, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, nil, errors.New("tus: invalid Upload-Checksum")
+	}
+	switch algorithm {
+	case "sha1":
+		return sha1.New(), sum, nil
+	case "sha256":
+		return sha256.New(), sum, nil
+	case "md5":
+		return md5.New(), sum, nil
+	}
+	return nil, nil, fmt.Errorf("tus: unsupported checksum algorithm %q", algorithm)
+}
+
+// chunkReader 限制一次 PATCH 的数据不超过剩余的大小 读完时检查校验和
+type chunkReader struct {
+	r         io.Reader
+	remaining int64
+	hash      hash.Hash
+	sum       []byte
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	// 多读一个字节 判断是否超过剩余的大小
+	if int64(len(p)) > c.remaining+1 {
+		p = p[:c.remaining+1]
+	}
+	n, err := c.r.Read(p)
+	if int64(n) > c.remaining {
+		// 超过大小的数据块整个丢弃 否则上传已经完成却返回 413
+		return 0, unverifiedError{ErrSizeExceeded}
+	}
+	c.remaining -= int64(n)
+	if c.hash == nil {
+		return n, err
+	}
+	c.hash.Write(p[:n])
+	if err == io.EOF && !bytes.Equal(c.hash.Sum(nil), c.sum) {
+		err = ErrChecksumMismatch
+	}
+	if err != nil && err != io.EOF && !errors.Is(err, ErrChecksumMismatch) {
+		// 数据不完整时无法校验 也要丢弃
+		err = unverifiedError{err}
+	}
+	return n, err
+}
+
+// unverifiedError 带有校验和的数据没有读完或者数据块超过了剩余的大小 Storage 需要像 ErrChecksumMismatch 一样丢弃这次写入的数据
+type unverifiedError struct {
+	err error
+}
+
+func (e unverifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e unverifiedError) Unwrap() error {
+	return e.err
+}
+
+func (e unverifiedError) Is(target error) bool {
+	return target == ErrChecksumMismatch
+}
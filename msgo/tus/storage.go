@@ -0,0 +1,194 @@
+package tus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Storage 保存上传的数据 Handler 保证同一个上传的 Append 和 Terminate 不会并发调用
+type Storage interface {
+	// Create 保存一个新的上传 info.ID 由 Handler 生成
+	Create(ctx context.Context, info Info) error
+	// Info 上传不存在时返回 ErrNotFound
+	Info(ctx context.Context, id string) (Info, error)
+	// Append 从 offset 开始写入 r 的数据 返回写入的字节数
+	// offset 和已经上传的字节数不一致时返回 ErrOffsetMismatch
+	// r 返回 ErrChecksumMismatch 时不能保留这次写入的任何数据
+	// r 返回其他错误时 例如连接断开 保留已经写入的数据 客户端可以从新的 offset 继续上传
+	Append(ctx context.Context, id string, offset int64, r io.Reader) (int64, error)
+	// Open 读取已经上传的数据
+	Open(ctx context.Context, id string) (io.ReadCloser, error)
+	// Terminate 删除上传和已经写入的数据
+	Terminate(ctx context.Context, id string) error
+}
+
+// LocalStore 把每个上传保存为 dir 中的两个文件 id.bin 保存数据 id.info 保存 Info
+type LocalStore struct {
+	dir string
+}
+
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalStore{dir: dir}, nil
+}
+
+// Path 返回上传数据所在的文件 上传完成后可以直接移动或者读取这个文件
+func (s *LocalStore) Path(id string) string {
+	return filepath.Join(s.dir, id+".bin")
+}
+
+func (s *LocalStore) infoPath(id string) string {
+	return filepath.Join(s.dir, id+".info")
+}
+
+func (s *LocalStore) Create(_ context.Context, info Info) error {
+	if !ValidID(info.ID) {
+		return ErrInvalidID
+	}
+	f, err := os.OpenFile(s.Path(info.ID), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := s.writeInfo(info); err != nil {
+		os.Remove(s.Path(info.ID))
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStore) Info(_ context.Context, id string) (Info, error) {
+	var info Info
+	if !ValidID(id) {
+		return info, ErrNotFound
+	}
+	data, err := os.ReadFile(s.infoPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return info, ErrNotFound
+	}
+	if err != nil {
+		return info, err
+	}
+	err = json.Unmarshal(data, &info)
+	return info, err
+}
+
+func (s *LocalStore) Append(ctx context.Context, id string, offset int64, r io.Reader) (int64, error) {
+	info, err := s.Info(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	if offset != info.Offset {
+		return 0, ErrOffsetMismatch
+	}
+	f, err := os.OpenFile(s.Path(id), os.O_WRONLY, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	// 上次写入失败时文件可能比 offset 长 从 offset 开始覆盖
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, r)
+	if errors.Is(err, ErrChecksumMismatch) {
+		f.Truncate(offset)
+		return 0, err
+	}
+	if syncErr := f.Sync(); syncErr != nil {
+		return 0, syncErr
+	}
+	if n == 0 {
+		return 0, err
+	}
+	info.Offset += n
+	if info.Complete() {
+		info.ExpiresAt = time.Time{}
+	}
+	if writeErr := s.writeInfo(info); writeErr != nil {
+		return 0, writeErr
+	}
+	return n, err
+}
+
+func (s *LocalStore) Open(_ context.Context, id string) (io.ReadCloser, error) {
+	if !ValidID(id) {
+		return nil, ErrNotFound
+	}
+	f, err := os.Open(s.Path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (s *LocalStore) Terminate(_ context.Context, id string) error {
+	if !ValidID(id) {
+		return ErrNotFound
+	}
+	err := os.Remove(s.infoPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return os.Remove(s.Path(id))
+}
+
+// RemoveExpired 删除在 now 时已经过期的未完成上传 返回删除的数量 可以定期调用
+func (s *LocalStore) RemoveExpired(ctx context.Context, now time.Time) (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, entry := range entries {
+		id := strings.TrimSuffix(entry.Name(), ".info")
+		if id == entry.Name() {
+			continue
+		}
+		info, err := s.Info(ctx, id)
+		if err != nil || !info.Expired(now) {
+			continue
+		}
+		if err := s.Terminate(ctx, id); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// writeInfo 先写入临时文件再重命名 读取时不会看到写了一半的 info
+func (s *LocalStore) writeInfo(info Info) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.dir, ".info-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.infoPath(info.ID))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,107 @@
+// Package tus 实现 tus 1.0.0 断点续传协议 https://tus.io/protocols/resumable-upload
+// 支持 creation checksum expiration termination 扩展
+package tus
+
+import (
+	"encoding/base64"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	Version = "1.0.0"
+	// Extensions 支持的扩展 通过 Tus-Extension 响应头告知客户端
+	Extensions = "creation,checksum,expiration,termination"
+	// ChecksumAlgorithms 支持的校验算法
+	ChecksumAlgorithms = "sha1,sha256,md5"
+
+	// StatusChecksumMismatch 校验和不一致时的状态码 tus checksum 扩展定义
+	StatusChecksumMismatch = 460
+)
+
+var (
+	ErrNotFound = errors.New("tus: upload not found")
+	// ErrOffsetMismatch Upload-Offset 和已经上传的字节数不一致
+	ErrOffsetMismatch = errors.New("tus: upload offset mismatch")
+	// ErrChecksumMismatch 这次 PATCH 的数据和 Upload-Checksum 不一致 这次写入的数据会被丢弃
+	ErrChecksumMismatch = errors.New("tus: checksum mismatch")
+	ErrSizeExceeded     = errors.New("tus: upload size exceeded")
+	ErrInvalidID        = errors.New("tus: invalid upload id")
+)
+
+// Info 一次上传的状态
+type Info struct {
+	ID       string            `json:"id"`
+	Size     int64             `json:"size"`
+	Offset   int64             `json:"offset"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// ExpiresAt 为零值时不过期 上传完成后不再过期
+	ExpiresAt time.Time `json:"expiresAt,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Complete 是否已经上传完成
+func (i Info) Complete() bool {
+	return i.Offset >= i.Size
+}
+
+// Expired 未完成的上传在 now 时是否已经过期
+func (i Info) Expired(now time.Time) bool {
+	return !i.Complete() && !i.ExpiresAt.IsZero() && now.After(i.ExpiresAt)
+}
+
+// ValidID id 只能包含字母 数字 - 和 _ 存储可以直接用它作为文件名
+func ValidID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseMetadata 解析 Upload-Metadata 格式为 key base64(value) 多个键值对用逗号分隔 值可以省略
+func ParseMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		if len(fields) == 0 || len(fields) > 2 {
+			return nil, errors.New("tus: invalid Upload-Metadata")
+		}
+		value := ""
+		if len(fields) == 2 {
+			b, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				return nil, errors.New("tus: invalid Upload-Metadata")
+			}
+			value = string(b)
+		}
+		metadata[fields[0]] = value
+	}
+	return metadata, nil
+}
+
+// FormatMetadata 和 ParseMetadata 相反 key 按字典序排列
+func FormatMetadata(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k
+		if v := metadata[k]; v != "" {
+			pairs[i] += " " + base64.StdEncoding.EncodeToString([]byte(v))
+		}
+	}
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,204 @@
+package tus_test
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"github.com/demo-go/msgo"
+	"github.com/demo-go/msgo/tus"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newServer(t *testing.T, config tus.Config) (*msgo.Engine, *tus.LocalStore) {
+	t.Helper()
+	store, err := tus.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Storage = store
+	engine := msgo.New()
+	engine.Group("api").Resumable("/files", tus.New(config))
+	return engine, store
+}
+
+func do(engine *msgo.Engine, method, target, body string, headers ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Header.Set("Tus-Resumable", tus.Version)
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, r)
+	return w
+}
+
+func patch(engine *msgo.Engine, location string, offset int, body string, headers ...string) *httptest.ResponseRecorder {
+	headers = append(headers, "Content-Type", "application/offset+octet-stream", "Upload-Offset", strconv.Itoa(offset))
+	return do(engine, http.MethodPatch, location, body, headers...)
+}
+
+func checksum(data string) string {
+	sum := sha1.Sum([]byte(data))
+	return "sha1 " + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func TestResumableUpload(t *testing.T) {
+	var completed tus.Info
+	engine, store := newServer(t, tus.Config{
+		MaxSize: 100,
+		OnComplete: func(r *http.Request, info tus.Info) {
+			completed = info
+		},
+	})
+
+	w := do(engine, http.MethodOptions, "/api/files", "")
+	if w.Code != http.StatusNoContent || w.Header().Get("Tus-Extension") == "" || w.Header().Get("Tus-Max-Size") != "100" {
+		t.Fatalf("options = %d %v", w.Code, w.Header())
+	}
+	if w = do(engine, http.MethodPost, "/api/files", "", "Upload-Length", "200"); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("create too large = %d", w.Code)
+	}
+
+	w = do(engine, http.MethodPost, "/api/files", "", "Upload-Length", "11",
+		"Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte("报告.txt"))+",private")
+	location := w.Header().Get("Location")
+	if w.Code != http.StatusCreated || !strings.HasPrefix(location, "/api/files/") || w.Header().Get("Upload-Expires") == "" {
+		t.Fatalf("create = %d %v", w.Code, w.Header())
+	}
+
+	if w = patch(engine, location, 0, "hello "); w.Code != http.StatusNoContent || w.Header().Get("Upload-Offset") != "6" {
+		t.Fatalf("patch = %d %v", w.Code, w.Header())
+	}
+	if w = patch(engine, location, 0, "hello "); w.Code != http.StatusConflict {
+		t.Fatalf("patch wrong offset = %d", w.Code)
+	}
+	// 校验和不一致时丢弃这次的数据
+	if w = patch(engine, location, 6, "wXrld", "Upload-Checksum", checksum("world")); w.Code != tus.StatusChecksumMismatch {
+		t.Fatalf("patch bad checksum = %d", w.Code)
+	}
+	w = do(engine, http.MethodHead, location, "")
+	if w.Code != http.StatusOK || w.Header().Get("Upload-Offset") != "6" || w.Header().Get("Upload-Length") != "11" {
+		t.Fatalf("head = %d %v", w.Code, w.Header())
+	}
+	if metadata, _ := tus.ParseMetadata(w.Header().Get("Upload-Metadata")); metadata["filename"] != "报告.txt" {
+		t.Fatalf("metadata = %v", metadata)
+	}
+	if w = patch(engine, location, 6, "world!"); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("patch too large = %d", w.Code)
+	}
+	if w = patch(engine, location, 6, "world", "Upload-Checksum", checksum("world")); w.Code != http.StatusNoContent {
+		t.Fatalf("patch = %d %s", w.Code, w.Body)
+	}
+	if completed.Size != 11 || !completed.Complete() {
+		t.Fatalf("completed = %+v", completed)
+	}
+	f, err := store.Open(context.Background(), completed.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(f)
+	f.Close()
+	if string(data) != "hello world" {
+		t.Fatalf("data = %q", data)
+	}
+
+	if w = do(engine, http.MethodDelete, location, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("delete = %d", w.Code)
+	}
+	if w = do(engine, http.MethodHead, location, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("head after delete = %d", w.Code)
+	}
+	r := httptest.NewRequest(http.MethodHead, location, nil)
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, r)
+	if w.Code != http.StatusPreconditionFailed || w.Header().Get("Tus-Version") != tus.Version {
+		t.Fatalf("missing Tus-Resumable = %d", w.Code)
+	}
+}
+
+func TestResumableExpiration(t *testing.T) {
+	engine, store := newServer(t, tus.Config{Expiration: time.Millisecond})
+	location := do(engine, http.MethodPost, "/api/files", "", "Upload-Length", "5").Header().Get("Location")
+	time.Sleep(5 * time.Millisecond)
+	if w := patch(engine, location, 0, "hello"); w.Code != http.StatusGone {
+		t.Fatalf("patch expired = %d", w.Code)
+	}
+	removed, err := store.RemoveExpired(context.Background(), time.Now())
+	if err != nil || removed != 1 {
+		t.Fatalf("removed = %d %v", removed, err)
+	}
+	id := location[strings.LastIndex(location, "/")+1:]
+	if _, err := os.Stat(store.Path(id)); !os.IsNotExist(err) {
+		t.Fatalf("data file left: %v", err)
+	}
+	if w := do(engine, http.MethodHead, "/api/files/..%2f..%2fetc", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("invalid id = %d", w.Code)
+	}
+}
+
+func TestResumableChunkTooLarge(t *testing.T) {
+	completed := false
+	engine, _ := newServer(t, tus.Config{
+		OnComplete: func(r *http.Request, info tus.Info) {
+			completed = true
+		},
+	})
+	location := do(engine, http.MethodPost, "/api/files", "", "Upload-Length", "5").Header().Get("Location")
+	// 没有 Content-Length 时读取过程中才发现超过剩余的大小 带不带校验和都返回 413
+	for _, headers := range [][]string{nil, {"Upload-Checksum", checksum("helloEXTRA")}} {
+		r := httptest.NewRequest(http.MethodPatch, location, strings.NewReader("helloEXTRA"))
+		r.ContentLength = -1
+		r.Header.Set("Tus-Resumable", tus.Version)
+		r.Header.Set("Content-Type", "application/offset+octet-stream")
+		r.Header.Set("Upload-Offset", "0")
+		for i := 0; i+1 < len(headers); i += 2 {
+			r.Header.Set(headers[i], headers[i+1])
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("patch chunked too large = %d %v", w.Code, headers)
+		}
+	}
+	if w := do(engine, http.MethodHead, location, ""); w.Header().Get("Upload-Offset") != "0" || completed {
+		t.Fatalf("offset = %s completed = %v", w.Header().Get("Upload-Offset"), completed)
+	}
+	if w := patch(engine, location, 0, "hello"); w.Code != http.StatusNoContent || !completed {
+		t.Fatalf("patch = %d completed = %v", w.Code, completed)
+	}
+}
+
+func TestResumableOptionsSkipsRouteMiddleware(t *testing.T) {
+	store, err := tus.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := func(next msgo.HandleFunc) msgo.HandleFunc {
+		return func(ctx *msgo.Context) {
+			if ctx.R.Header.Get("Authorization") == "" {
+				ctx.W.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next(ctx)
+		}
+	}
+	engine := msgo.New()
+	engine.Group("api").Resumable("/files", tus.New(tus.Config{Storage: store}), auth)
+
+	for _, target := range []string{"/api/files", "/api/files/abc"} {
+		w := do(engine, http.MethodOptions, target, "")
+		if w.Code != http.StatusNoContent || w.Header().Get("Tus-Version") != tus.Version || w.Header().Get("Tus-Extension") == "" {
+			t.Fatalf("options %s = %d %v", target, w.Code, w.Header())
+		}
+	}
+	if w := do(engine, http.MethodPost, "/api/files", "", "Upload-Length", "5"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("create without auth = %d", w.Code)
+	}
+}
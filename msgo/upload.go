@@ -0,0 +1,362 @@
+package msgo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const defaultMultipartMemory = 32 << 20
+
+var (
+	// ErrBodyTooLarge 请求体超过了 UploadConfig.MaxBodySize 可以返回 413
+	ErrBodyTooLarge = errors.New("msgo: request body too large")
+	// ErrFileTooLarge 上传的文件超过了 UploadConfig.MaxFileSize
+	ErrFileTooLarge = errors.New("msgo: uploaded file too large")
+	// ErrFileType 上传的文件类型不在 UploadConfig.AllowedTypes 中
+	ErrFileType = errors.New("msgo: uploaded file type not allowed")
+)
+
+type UploadConfig struct {
+	// MaxBodySize 请求体的最大字节数 超过后读取请求体返回 ErrBodyTooLarge 默认不限制
+	MaxBodySize int64
+	// MaxFileSize 单个文件的最大字节数 默认不限制
+	MaxFileSize int64
+	// MaxMemory 解析 multipart 时保存在内存中的最大字节数 超过的部分写入临时文件 默认 32MB
+	MaxMemory int64
+	// AllowedTypes 允许的文件类型 根据文件内容检测而不是客户端传来的 Content-Type
+	// 支持 image/* 这样的通配 为空时不限制
+	AllowedTypes []string
+}
+
+// Upload 为路由设置上传限制 FormFile FormFiles 和 SaveUploadFileTo 会按照这些限制检查文件
+func Upload(config UploadConfig) MiddlewareFunc {
+	if config.MaxMemory <= 0 {
+		config.MaxMemory = defaultMultipartMemory
+	}
+	return func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			if config.MaxBodySize > 0 {
+				if ctx.R.ContentLength > config.MaxBodySize {
+					ctx.W.WriteHeader(http.StatusRequestEntityTooLarge)
+					fmt.Fprintf(ctx.W, "%s request body too large \n", ctx.R.RequestURI)
+					return
+				}
+				if ctx.R.Body != nil && ctx.R.Body != http.NoBody {
+					ctx.R.Body = &limitedBody{ReadCloser: ctx.R.Body, remaining: config.MaxBodySize}
+				}
+			}
+			ctx.upload = &config
+			next(ctx)
+		}
+	}
+}
+
+// limitedBody 和 http.MaxBytesReader 相同 但返回 ErrBodyTooLarge 方便判断
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	err       error
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	// 多读一个字节 判断是否超过限制
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) <= b.remaining {
+		b.remaining -= int64(n)
+		b.err = err
+		return n, err
+	}
+	n = int(b.remaining)
+	b.remaining = 0
+	b.err = ErrBodyTooLarge
+	return n, b.err
+}
+
+func (c *Context) maxMultipartMemory() int64 {
+	if c.upload != nil {
+		return c.upload.MaxMemory
+	}
+	return defaultMultipartMemory
+}
+
+func (c *Context) MultipartForm() (*multipart.Form, error) {
+	err := c.R.ParseMultipartForm(c.maxMultipartMemory())
+	return c.R.MultipartForm, bodyError(err, c.R.Body)
+}
+
+// bodyError multipart 会用 %v 包装读取请求体的错误 errors.Is 无法识别
+// 这时根据 limitedBody 记录的错误还原为 ErrBodyTooLarge
+func bodyError(err error, body io.Reader) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, ErrBodyTooLarge) {
+		return ErrBodyTooLarge
+	}
+	if b, ok := body.(*limitedBody); ok && b.err == ErrBodyTooLarge {
+		return ErrBodyTooLarge
+	}
+	return err
+}
+
+// FormFile 返回第一个名为 name 的文件 文件不存在时返回 http.ErrMissingFile
+// 设置了 Upload 中间件时检查文件大小和类型
+func (c *Context) FormFile(name string) (*multipart.FileHeader, error) {
+	files, err := c.FormFiles(name)
+	if err != nil {
+		return nil, err
+	}
+	return files[0], nil
+}
+
+// FormFiles 返回所有名为 name 的文件 任意一个文件不满足限制时返回错误
+func (c *Context) FormFiles(name string) ([]*multipart.FileHeader, error) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, err
+	}
+	if form == nil || len(form.File[name]) == 0 {
+		return nil, http.ErrMissingFile
+	}
+	files := form.File[name]
+	for _, file := range files {
+		if err := c.checkUploadFile(file); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func (c *Context) checkUploadFile(file *multipart.FileHeader) error {
+	if c.upload == nil {
+		return nil
+	}
+	if c.upload.MaxFileSize > 0 && file.Size > c.upload.MaxFileSize {
+		return fmt.Errorf("%w: %s is %d bytes", ErrFileTooLarge, SanitizeFilename(file.Filename), file.Size)
+	}
+	if len(c.upload.AllowedTypes) == 0 {
+		return nil
+	}
+	contentType, err := DetectFileType(file)
+	if err != nil {
+		return err
+	}
+	if typeAllowed(c.upload.AllowedTypes, contentType) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s is %s", ErrFileType, SanitizeFilename(file.Filename), contentType)
+}
+
+// typeAllowed allowed 为空时允许所有类型 支持 image/* 这样的通配
+func typeAllowed(allowed []string, contentType string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, t := range allowed {
+		if t == contentType || strings.HasSuffix(t, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(t, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// DetectFileType 根据文件的前 512 个字节检测类型 不包含 charset 等参数
+func DetectFileType(file *multipart.FileHeader) (string, error) {
+	f, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	return detectContentType(buf[:n]), nil
+}
+
+func detectContentType(data []byte) string {
+	contentType := http.DetectContentType(data)
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		contentType = mediaType
+	}
+	return contentType
+}
+
+// SaveUploadFile 把文件保存到 dst dst 由调用方决定 不要直接拼接 file.Filename
+// 保存到目录时使用 SaveUploadFileTo
+func (c *Context) SaveUploadFile(file *multipart.FileHeader, dst string) error {
+	if err := c.checkUploadFile(file); err != nil {
+		return err
+	}
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	tmp, err := writeTempFile(src, filepath.Dir(dst))
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// SaveUploadFileTo 把文件保存到 dir 目录中 返回保存的路径
+// 文件名经过 SanitizeFilename 处理 不会覆盖已有的文件 同名时加上 -1 -2 这样的后缀
+// 文件先写入临时文件再链接到最终的路径 其他请求不会看到写了一半的文件
+func (c *Context) SaveUploadFileTo(file *multipart.FileHeader, dir string) (string, error) {
+	if err := c.checkUploadFile(file); err != nil {
+		return "", err
+	}
+	src, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+	return saveUnique(src, dir, file.Filename)
+}
+
+// saveUnique 把 src 写入临时文件 再以不冲突的文件名链接到 dir 中
+func saveUnique(src io.Reader, dir, filename string) (string, error) {
+	tmp, err := writeTempFile(src, dir)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp)
+
+	name := SanitizeFilename(filename)
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	for i := 0; i < 1000; i++ {
+		candidate := name
+		if i > 0 {
+			candidate = stem + "-" + strconv.Itoa(i) + ext
+		}
+		dst := filepath.Join(dir, candidate)
+		err = linkOrCopy(tmp, dst)
+		if err == nil {
+			return dst, nil
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("msgo: too many files named %s in %s", name, dir)
+}
+
+// linkFile 测试时可以替换 模拟不支持硬链接的文件系统
+var linkFile = os.Link
+
+// linkOrCopy 把 src 保存为 dst dst 已经存在时返回 os.ErrExist 不会覆盖其他请求刚刚保存的文件
+// 文件系统不支持硬链接时 例如 FAT 和部分网络文件系统 使用 O_EXCL 创建 dst 后复制内容
+func linkOrCopy(src, dst string) error {
+	err := linkFile(src, dst)
+	if err == nil || os.IsExist(err) {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if err == nil {
+		err = out.Sync()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dst)
+	}
+	return err
+}
+
+func writeTempFile(src io.Reader, dir string) (string, error) {
+	out, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(out, src)
+	if err == nil {
+		err = out.Sync()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+	return out.Name(), nil
+}
+
+// windowsReserved Windows 中不能作为文件名的设备名
+var windowsReserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// SanitizeFilename 把客户端传来的文件名转换为安全的文件名
+// 去掉目录 控制字符和 Windows 不允许的字符 不以 . 开头 最长 255 字节 结果为空时返回 file
+func SanitizeFilename(name string) string {
+	// 浏览器可能传来完整的 Windows 路径
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.Map(func(r rune) rune {
+		if r == utf8.RuneError || unicode.IsControl(r) || strings.ContainsRune(`<>:"|?*`, r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimLeft(strings.TrimSpace(name), ".")
+	name = strings.TrimRight(name, ". ")
+	if stem := strings.ToUpper(strings.TrimSuffix(name, filepath.Ext(name))); windowsReserved[stem] {
+		name = "_" + name
+	}
+	if len(name) > 255 {
+		ext := filepath.Ext(name)
+		if len(ext) > 32 {
+			ext = ""
+		}
+		stem := name[:255-len(ext)]
+		// 不截断多字节字符
+		for !utf8.ValidString(stem) {
+			stem = stem[:len(stem)-1]
+		}
+		name = stem + ext
+	}
+	if name == "" {
+		return "file"
+	}
+	return name
+}
@@ -0,0 +1,163 @@
+package msgo
+
+import (
+	"bytes"
+	"errors"
+	"github.com/demo-go/msgo/tracing"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func multipartRequest(t *testing.T, filename string, content []byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(content)
+	mw.Close()
+	r := httptest.NewRequest(http.MethodPost, "/user/upload", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func TestUpload(t *testing.T) {
+	dir := t.TempDir()
+	engine := New()
+	var saved []string
+	var errs []error
+	engine.Group("user").Post("/upload", func(ctx *Context) {
+		file, err := ctx.FormFile("file")
+		if err == nil {
+			var path string
+			path, err = ctx.SaveUploadFileTo(file, dir)
+			saved = append(saved, path)
+		}
+		errs = append(errs, err)
+	}, Upload(UploadConfig{MaxBodySize: 1024, MaxFileSize: 100, AllowedTypes: []string{"image/*"}}))
+
+	send := func(r *http.Request) (int, error) {
+		errs = nil
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, r)
+		if len(errs) == 0 {
+			return w.Code, nil
+		}
+		return w.Code, errs[0]
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := send(multipartRequest(t, `..\..\etc/passwd.png`, pngHeader)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{filepath.Join(dir, "passwd.png"), filepath.Join(dir, "passwd-1.png")}
+	if len(saved) != 2 || saved[0] != want[0] || saved[1] != want[1] {
+		t.Fatalf("saved = %v", saved)
+	}
+	if data, err := os.ReadFile(saved[1]); err != nil || !bytes.Equal(data, pngHeader) {
+		t.Fatalf("content = %q %v", data, err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Fatalf("temporary files left: %d entries", len(entries))
+	}
+
+	if _, err := send(multipartRequest(t, "a.png", []byte("just text"))); !errors.Is(err, ErrFileType) {
+		t.Fatalf("err = %v", err)
+	}
+	if _, err := send(multipartRequest(t, "a.png", append(pngHeader, make([]byte, 200)...))); !errors.Is(err, ErrFileTooLarge) {
+		t.Fatalf("err = %v", err)
+	}
+
+	large := multipartRequest(t, "a.png", append(pngHeader, make([]byte, 2000)...))
+	if code, _ := send(large); code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("code = %d", code)
+	}
+	// 没有 Content-Length 时读取请求体的过程中发现超过限制
+	large = multipartRequest(t, "a.png", append(pngHeader, make([]byte, 2000)...))
+	large.ContentLength = -1
+	if _, err := send(large); !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	cases := map[string]string{
+		"photo.jpg":              "photo.jpg",
+		"../../etc/passwd":       "passwd",
+		`C:\Users\me\report.pdf`: "report.pdf",
+		".htaccess":              "htaccess",
+		"a<b>c:d\"e|f?g*.txt":    "abcdefg.txt",
+		"con.txt":                "_con.txt",
+		"..":                     "file",
+		"name\x00with\nctrl.png": "namewithctrl.png",
+		strings.Repeat("文", 100): strings.Repeat("文", 85),
+	}
+	for in, want := range cases {
+		if got := SanitizeFilename(in); got != want {
+			t.Errorf("SanitizeFilename(%q) = %q want %q", in, got, want)
+		}
+	}
+}
+
+func TestMultipartTempFilesRemoved(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	engine := New()
+	engine.Tracer = tracing.NewTracer(tracing.Config{})
+	g := engine.Group("user")
+	// 这些中间件都会通过 WithContext 替换 ctx.R
+	g.Use(RequestID(RequestIDConfig{}), Timeout(TimeoutConfig{Timeout: time.Second}))
+	g.Post("/upload", func(ctx *Context) {
+		if _, err := ctx.FormFile("file"); err != nil {
+			t.Error(err)
+		}
+	}, Upload(UploadConfig{MaxMemory: 1024}))
+
+	w := httptest.NewRecorder()
+	r := multipartRequest(t, "a.png", append(pngHeader, make([]byte, 1<<20)...))
+	engine.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("code = %d", w.Code)
+	}
+	// 和 net/http 一样清理原始请求上的表单
+	if r.MultipartForm != nil {
+		r.MultipartForm.RemoveAll()
+	}
+	if entries, _ := os.ReadDir(tmp); len(entries) != 0 {
+		t.Fatalf("temporary files left: %d entries", len(entries))
+	}
+}
+
+func TestSaveWithoutHardLinks(t *testing.T) {
+	// 模拟不支持硬链接的文件系统
+	linkFile = func(oldname, newname string) error {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: errors.New("operation not permitted")}
+	}
+	defer func() { linkFile = os.Link }()
+
+	dir := t.TempDir()
+	for i, want := range []string{"a.png", "a-1.png"} {
+		path, err := saveUnique(bytes.NewReader(pngHeader), dir, "a.png")
+		if err != nil || path != filepath.Join(dir, want) {
+			t.Fatalf("save %d = %q %v", i, path, err)
+		}
+		if data, _ := os.ReadFile(path); !bytes.Equal(data, pngHeader) {
+			t.Fatalf("content = %q", data)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Fatalf("temporary files left: %d entries", len(entries))
+	}
+}
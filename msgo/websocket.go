@@ -0,0 +1,43 @@
+package msgo
+
+import (
+	"bufio"
+	"errors"
+	"github.com/demo-go/msgo/websocket"
+	"log"
+	"net"
+	"net/http"
+)
+
+type WebSocketHandleFunc func(ctx *Context, conn *websocket.Conn)
+
+var defaultUpgrader = &websocket.Upgrader{}
+
+// WebSocket 注册一个 GET 路由 握手成功后交给 handler 处理 handler 返回时关闭连接
+func (r *routerGroup) WebSocket(name string, handler WebSocketHandleFunc, middlewareFunc ...MiddlewareFunc) {
+	r.Get(name, func(ctx *Context) {
+		conn, err := ctx.Upgrade(nil)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		defer conn.Close()
+		handler(ctx, conn)
+	}, middlewareFunc...)
+}
+
+func (c *Context) Upgrade(responseHeader http.Header) (*websocket.Conn, error) {
+	upgrader := defaultUpgrader
+	if c.engine != nil && c.engine.WebSocketUpgrader != nil {
+		upgrader = c.engine.WebSocketUpgrader
+	}
+	return upgrader.Upgrade(c.W, c.R, responseHeader)
+}
+
+func (c *Context) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := c.W.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the ResponseWriter doesn't support the Hijacker interface")
+	}
+	return hijacker.Hijack()
+}
@@ -0,0 +1,97 @@
+package websocket
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+)
+
+const defaultCompressionLevel = 1
+
+// permessage-deflate 每条消息末尾省略的同步标记 RFC 7692 7.2.1
+var deflateTail = []byte{0x00, 0x00, 0xff, 0xff}
+
+// 解压时在同步标记后再补一个空的结束块 让 flate.Reader 正常读到 EOF
+var inflateTail = []byte{0x00, 0x00, 0xff, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff}
+
+var errMessageTooBig = errors.New("websocket: message too big")
+
+// flateWriterPools 按压缩级别缓存 flate.Writer 下标为 level - flate.HuffmanOnly
+var flateWriterPools [flate.BestCompression - flate.HuffmanOnly + 1]sync.Pool
+
+var flateReaderPool = sync.Pool{New: func() any {
+	return flate.NewReader(nil)
+}}
+
+func compress(data []byte, level int) ([]byte, error) {
+	var buf bytes.Buffer
+	pool := &flateWriterPools[level-flate.HuffmanOnly]
+	fw, _ := pool.Get().(*flate.Writer)
+	if fw == nil {
+		var err error
+		fw, err = flate.NewWriter(&buf, level)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		fw.Reset(&buf)
+	}
+	defer pool.Put(fw)
+	if _, err := fw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := fw.Flush(); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), deflateTail), nil
+}
+
+func decompress(data []byte, limit int64) ([]byte, error) {
+	src := io.MultiReader(bytes.NewReader(data), bytes.NewReader(inflateTail))
+	fr := flateReaderPool.Get().(io.ReadCloser)
+	defer flateReaderPool.Put(fr)
+	if err := fr.(flate.Resetter).Reset(src, nil); err != nil {
+		return nil, err
+	}
+	var r io.Reader = fr
+	if limit > 0 {
+		r = io.LimitReader(fr, limit+1)
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && int64(len(out)) > limit {
+		return nil, errMessageTooBig
+	}
+	return out, nil
+}
+
+// negotiateDeflate 解析 Sec-WebSocket-Extensions 头 只支持不保留上下文的 permessage-deflate
+func negotiateDeflate(header []string) bool {
+	for _, value := range header {
+		for _, ext := range strings.Split(value, ",") {
+			params := strings.Split(ext, ";")
+			if strings.TrimSpace(params[0]) != "permessage-deflate" {
+				continue
+			}
+			ok := true
+			for _, p := range params[1:] {
+				name := strings.TrimSpace(strings.SplitN(p, "=", 2)[0])
+				switch name {
+				case "server_no_context_takeover", "client_no_context_takeover", "client_max_window_bits":
+				default:
+					// 不支持的参数 例如 server_max_window_bits 跳过这个候选
+					ok = false
+				}
+			}
+			if ok {
+				return true
+			}
+		}
+	}
+	return false
+}
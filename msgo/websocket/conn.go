@@ -0,0 +1,512 @@
+package websocket
+
+import (
+	"bufio"
+	"compress/flate"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// 消息类型 对应 RFC 6455 中的 opcode
+const (
+	continuationFrame = 0
+	TextMessage       = 1
+	BinaryMessage     = 2
+	CloseMessage      = 8
+	PingMessage       = 9
+	PongMessage       = 10
+)
+
+// 关闭码 RFC 6455 7.4.1
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseAbnormalClosure         = 1006
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseMandatoryExtension      = 1010
+	CloseInternalServerErr       = 1011
+)
+
+const (
+	finalBit = 1 << 7
+	rsv1Bit  = 1 << 6
+	rsv2Bit  = 1 << 5
+	rsv3Bit  = 1 << 4
+	maskBit  = 1 << 7
+
+	maxControlFramePayloadSize = 125
+	defaultReadLimit           = 32 << 20
+)
+
+var ErrCloseSent = errors.New("websocket: close sent")
+
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: close %d %s", e.Code, e.Text)
+}
+
+func IsCloseError(err error, codes ...int) bool {
+	var ce *CloseError
+	if !errors.As(err, &ce) {
+		return false
+	}
+	for _, code := range codes {
+		if ce.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+type Conn struct {
+	conn        net.Conn
+	br          *bufio.Reader
+	isServer    bool
+	subprotocol string
+
+	writeMu   sync.Mutex
+	closeSent bool
+
+	negotiated        bool
+	compress          bool
+	compressLevel     int
+	writeFragmentSize int
+
+	readLimit   int64
+	readErr     error
+	pingHandler func(data string) error
+	pongHandler func(data string) error
+}
+
+func newConn(conn net.Conn, br *bufio.Reader, isServer bool) *Conn {
+	if br == nil {
+		br = bufio.NewReader(conn)
+	}
+	c := &Conn{
+		conn:          conn,
+		br:            br,
+		isServer:      isServer,
+		readLimit:     defaultReadLimit,
+		compressLevel: defaultCompressionLevel,
+	}
+	c.pingHandler = func(data string) error {
+		err := c.WriteControl(PongMessage, []byte(data))
+		if errors.Is(err, ErrCloseSent) {
+			return nil
+		}
+		return err
+	}
+	c.pongHandler = func(string) error { return nil }
+	return c
+}
+
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+func (c *Conn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// SetReadLimit 设置单条消息的最大字节数 超过后以 1009 关闭连接
+func (c *Conn) SetReadLimit(limit int64) {
+	c.readLimit = limit
+}
+
+// SetWriteFragmentSize 大于 0 时 超过该长度的消息会拆成多个分片发送
+func (c *Conn) SetWriteFragmentSize(size int) {
+	c.writeFragmentSize = size
+}
+
+// EnableWriteCompression 仅在握手时协商了 permessage-deflate 的情况下生效
+func (c *Conn) EnableWriteCompression(enable bool) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.compress = enable && c.negotiated
+}
+
+func (c *Conn) SetCompressionLevel(level int) error {
+	if level < flate.HuffmanOnly || level > flate.BestCompression {
+		return errors.New("websocket: invalid compression level")
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.compressLevel = level
+	return nil
+}
+
+func (c *Conn) SetPingHandler(h func(data string) error) {
+	if h == nil {
+		h = func(string) error { return nil }
+	}
+	c.pingHandler = h
+}
+
+func (c *Conn) SetPongHandler(h func(data string) error) {
+	if h == nil {
+		h = func(string) error { return nil }
+	}
+	c.pongHandler = h
+}
+
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	if c.readErr != nil {
+		return 0, nil, c.readErr
+	}
+	messageType, p, err := c.readMessage()
+	if err != nil {
+		c.readErr = err
+	}
+	return messageType, p, err
+}
+
+func (c *Conn) readMessage() (int, []byte, error) {
+	messageType := 0
+	compressed := false
+	var message []byte
+	for {
+		h, err := c.readFrameHeader()
+		if err != nil {
+			return 0, nil, err
+		}
+		if h.rsv2 || h.rsv3 || (h.rsv1 && !c.negotiated) {
+			return 0, nil, c.fail(CloseProtocolError, "unexpected reserved bits")
+		}
+		if h.masked != c.isServer {
+			return 0, nil, c.fail(CloseProtocolError, "incorrect mask flag")
+		}
+		if h.opcode >= CloseMessage {
+			if !h.fin || h.length > maxControlFramePayloadSize || h.rsv1 {
+				return 0, nil, c.fail(CloseProtocolError, "invalid control frame")
+			}
+			// 0xB 到 0xF 是保留的控制帧
+			if h.opcode != CloseMessage && h.opcode != PingMessage && h.opcode != PongMessage {
+				return 0, nil, c.fail(CloseProtocolError, fmt.Sprintf("unknown opcode %d", h.opcode))
+			}
+			payload, err := c.readPayload(h)
+			if err != nil {
+				return 0, nil, err
+			}
+			if err := c.handleControl(h.opcode, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		}
+		switch h.opcode {
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, c.fail(CloseProtocolError, "expected continuation frame")
+			}
+			messageType = h.opcode
+			compressed = h.rsv1
+		case continuationFrame:
+			if messageType == 0 {
+				return 0, nil, c.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+			if h.rsv1 {
+				return 0, nil, c.fail(CloseProtocolError, "reserved bit on continuation frame")
+			}
+		default:
+			return 0, nil, c.fail(CloseProtocolError, fmt.Sprintf("unknown opcode %d", h.opcode))
+		}
+		if c.readLimit > 0 && int64(len(message))+h.length > c.readLimit {
+			return 0, nil, c.fail(CloseMessageTooBig, "message too big")
+		}
+		payload, err := c.readPayload(h)
+		if err != nil {
+			return 0, nil, err
+		}
+		message = append(message, payload...)
+		if !h.fin {
+			continue
+		}
+		if compressed {
+			message, err = decompress(message, c.readLimit)
+			if errors.Is(err, errMessageTooBig) {
+				return 0, nil, c.fail(CloseMessageTooBig, "message too big")
+			}
+			if err != nil {
+				return 0, nil, c.fail(CloseProtocolError, "invalid compressed data")
+			}
+		}
+		if messageType == TextMessage && !utf8.Valid(message) {
+			return 0, nil, c.fail(CloseInvalidFramePayloadData, "invalid utf8 payload")
+		}
+		return messageType, message, nil
+	}
+}
+
+type frameHeader struct {
+	fin    bool
+	rsv1   bool
+	rsv2   bool
+	rsv3   bool
+	opcode int
+	masked bool
+	length int64
+	mask   [4]byte
+}
+
+func (c *Conn) readFrameHeader() (frameHeader, error) {
+	var h frameHeader
+	var b [8]byte
+	if _, err := io.ReadFull(c.br, b[:2]); err != nil {
+		return h, c.abnormal(err)
+	}
+	h.fin = b[0]&finalBit != 0
+	h.rsv1 = b[0]&rsv1Bit != 0
+	h.rsv2 = b[0]&rsv2Bit != 0
+	h.rsv3 = b[0]&rsv3Bit != 0
+	h.opcode = int(b[0] & 0xf)
+	h.masked = b[1]&maskBit != 0
+	h.length = int64(b[1] & 0x7f)
+	switch h.length {
+	case 126:
+		if _, err := io.ReadFull(c.br, b[:2]); err != nil {
+			return h, c.abnormal(err)
+		}
+		h.length = int64(binary.BigEndian.Uint16(b[:2]))
+	case 127:
+		if _, err := io.ReadFull(c.br, b[:8]); err != nil {
+			return h, c.abnormal(err)
+		}
+		length := binary.BigEndian.Uint64(b[:8])
+		if length>>63 != 0 {
+			return h, c.fail(CloseProtocolError, "invalid payload length")
+		}
+		h.length = int64(length)
+	}
+	if h.masked {
+		if _, err := io.ReadFull(c.br, h.mask[:]); err != nil {
+			return h, c.abnormal(err)
+		}
+	}
+	return h, nil
+}
+
+func (c *Conn) readPayload(h frameHeader) ([]byte, error) {
+	payload := make([]byte, h.length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return nil, c.abnormal(err)
+	}
+	if h.masked {
+		maskBytes(h.mask, payload)
+	}
+	return payload, nil
+}
+
+func (c *Conn) handleControl(opcode int, payload []byte) error {
+	switch opcode {
+	case PingMessage:
+		return c.pingHandler(string(payload))
+	case PongMessage:
+		return c.pongHandler(string(payload))
+	}
+	// close frame
+	code := CloseNoStatusReceived
+	text := ""
+	if len(payload) == 1 {
+		return c.fail(CloseProtocolError, "invalid close payload")
+	}
+	if len(payload) >= 2 {
+		code = int(binary.BigEndian.Uint16(payload))
+		text = string(payload[2:])
+		if !validCloseCode(code) {
+			return c.fail(CloseProtocolError, "invalid close code")
+		}
+		if !utf8.ValidString(text) {
+			return c.fail(CloseInvalidFramePayloadData, "invalid utf8 close reason")
+		}
+	}
+	// 回应对端的关闭帧
+	reply := code
+	if reply == CloseNoStatusReceived {
+		reply = CloseNormalClosure
+	}
+	_ = c.WriteControl(CloseMessage, FormatCloseMessage(reply, ""))
+	return &CloseError{Code: code, Text: text}
+}
+
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1011:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
+// fail 以指定的关闭码通知对端 并返回对应的错误
+func (c *Conn) fail(code int, text string) error {
+	_ = c.WriteControl(CloseMessage, FormatCloseMessage(code, text))
+	return &CloseError{Code: code, Text: text}
+}
+
+func (c *Conn) abnormal(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return &CloseError{Code: CloseAbnormalClosure, Text: err.Error()}
+	}
+	return err
+}
+
+func FormatCloseMessage(code int, text string) []byte {
+	if code == CloseNoStatusReceived {
+		return []byte{}
+	}
+	if len(text) > maxControlFramePayloadSize-2 {
+		text = text[:maxControlFramePayloadSize-2]
+	}
+	buf := make([]byte, 2+len(text))
+	binary.BigEndian.PutUint16(buf, uint16(code))
+	copy(buf[2:], text)
+	return buf
+}
+
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return c.WriteControl(messageType, data)
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return ErrCloseSent
+	}
+	rsv1 := false
+	if c.compress {
+		compressed, err := compress(data, c.compressLevel)
+		if err != nil {
+			return err
+		}
+		data = compressed
+		rsv1 = true
+	}
+	opcode := messageType
+	for {
+		chunk := data
+		if c.writeFragmentSize > 0 && len(chunk) > c.writeFragmentSize {
+			chunk = data[:c.writeFragmentSize]
+		}
+		data = data[len(chunk):]
+		fin := len(data) == 0
+		if err := c.writeFrame(fin, rsv1, opcode, chunk); err != nil {
+			return err
+		}
+		if fin {
+			return nil
+		}
+		opcode = continuationFrame
+		rsv1 = false
+	}
+}
+
+func (c *Conn) WriteControl(messageType int, data []byte) error {
+	if messageType != CloseMessage && messageType != PingMessage && messageType != PongMessage {
+		return fmt.Errorf("websocket: bad control message type %d", messageType)
+	}
+	if len(data) > maxControlFramePayloadSize {
+		return errors.New("websocket: control frame payload too long")
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return ErrCloseSent
+	}
+	if messageType == CloseMessage {
+		c.closeSent = true
+	}
+	return c.writeFrame(true, false, messageType, data)
+}
+
+func (c *Conn) writeFrame(fin, rsv1 bool, opcode int, payload []byte) error {
+	frame := make([]byte, 0, len(payload)+14)
+	b0 := byte(opcode)
+	if fin {
+		b0 |= finalBit
+	}
+	if rsv1 {
+		b0 |= rsv1Bit
+	}
+	frame = append(frame, b0)
+	b1 := byte(0)
+	if !c.isServer {
+		b1 |= maskBit
+	}
+	length := len(payload)
+	switch {
+	case length <= 125:
+		frame = append(frame, b1|byte(length))
+	case length <= 0xffff:
+		frame = append(frame, b1|126, byte(length>>8), byte(length))
+	default:
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], uint64(length))
+		frame = append(frame, b1|127)
+		frame = append(frame, b[:]...)
+	}
+	if c.isServer {
+		frame = append(frame, payload...)
+	} else {
+		// 客户端发送的帧必须加掩码
+		var key [4]byte
+		if _, err := rand.Read(key[:]); err != nil {
+			return err
+		}
+		frame = append(frame, key[:]...)
+		start := len(frame)
+		frame = append(frame, payload...)
+		maskBytes(key, frame[start:])
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+func maskBytes(key [4]byte, b []byte) {
+	for i := range b {
+		b[i] ^= key[i&3]
+	}
+}
+
+// Close 发送正常关闭帧并关闭底层连接
+func (c *Conn) Close() error {
+	return c.CloseWithCode(CloseNormalClosure, "")
+}
+
+func (c *Conn) CloseWithCode(code int, text string) error {
+	err := c.WriteControl(CloseMessage, FormatCloseMessage(code, text))
+	if errors.Is(err, ErrCloseSent) {
+		err = nil
+	}
+	if cerr := c.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
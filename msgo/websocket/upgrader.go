@@ -0,0 +1,169 @@
+package websocket
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+type HandshakeError struct {
+	Status  int
+	Message string
+}
+
+func (e *HandshakeError) Error() string {
+	return "websocket: " + e.Message
+}
+
+type Upgrader struct {
+	// CheckOrigin 为空时只允许同源请求
+	CheckOrigin       func(r *http.Request) bool
+	Subprotocols      []string
+	EnableCompression bool
+	ReadLimit         int64
+	WriteFragmentSize int
+}
+
+func (u *Upgrader) Upgrade(w http.ResponseWriter, r *http.Request, responseHeader http.Header) (*Conn, error) {
+	if r.Method != http.MethodGet {
+		return u.error(w, http.StatusMethodNotAllowed, "request method is not GET")
+	}
+	if !headerContainsToken(r.Header, "Connection", "upgrade") {
+		return u.error(w, http.StatusBadRequest, "'upgrade' token not found in 'Connection' header")
+	}
+	if !headerContainsToken(r.Header, "Upgrade", "websocket") {
+		return u.error(w, http.StatusBadRequest, "'websocket' token not found in 'Upgrade' header")
+	}
+	if r.Header.Get("Sec-Websocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return u.error(w, http.StatusUpgradeRequired, "unsupported version")
+	}
+	checkOrigin := u.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(r) {
+		return u.error(w, http.StatusForbidden, "request origin not allowed")
+	}
+	key := r.Header.Get("Sec-Websocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return u.error(w, http.StatusBadRequest, "'Sec-WebSocket-Key' header is missing or invalid")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return u.error(w, http.StatusInternalServerError, "response does not implement http.Hijacker")
+	}
+
+	subprotocol := u.selectSubprotocol(r)
+	compress := u.EnableCompression && negotiateDeflate(r.Header["Sec-Websocket-Extensions"])
+
+	netConn, brw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	// 清除 http.Server 设置的超时
+	_ = netConn.SetDeadline(time.Time{})
+
+	var b strings.Builder
+	b.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: ")
+	b.WriteString(computeAcceptKey(key))
+	b.WriteString("\r\n")
+	if subprotocol != "" {
+		b.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+	}
+	if compress {
+		b.WriteString("Sec-WebSocket-Extensions: permessage-deflate; server_no_context_takeover; client_no_context_takeover\r\n")
+	}
+	for k, vs := range responseHeader {
+		if k == "Sec-Websocket-Protocol" || k == "Sec-Websocket-Extensions" {
+			continue
+		}
+		for _, v := range vs {
+			b.WriteString(k + ": " + v + "\r\n")
+		}
+	}
+	b.WriteString("\r\n")
+	if _, err := netConn.Write([]byte(b.String())); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	c := newConn(netConn, brw.Reader, true)
+	c.subprotocol = subprotocol
+	c.negotiated = compress
+	c.compress = compress
+	c.writeFragmentSize = u.WriteFragmentSize
+	if u.ReadLimit > 0 {
+		c.readLimit = u.ReadLimit
+	}
+	return c, nil
+}
+
+func (u *Upgrader) error(w http.ResponseWriter, status int, message string) (*Conn, error) {
+	err := &HandshakeError{Status: status, Message: message}
+	http.Error(w, http.StatusText(status), status)
+	return nil, err
+}
+
+func (u *Upgrader) selectSubprotocol(r *http.Request) string {
+	for _, requested := range Subprotocols(r) {
+		for _, supported := range u.Subprotocols {
+			if requested == supported {
+				return supported
+			}
+		}
+	}
+	return ""
+}
+
+// Subprotocols 返回客户端在 Sec-WebSocket-Protocol 中请求的子协议
+func Subprotocols(r *http.Request) []string {
+	var protocols []string
+	for _, value := range r.Header["Sec-Websocket-Protocol"] {
+		for _, p := range strings.Split(value, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				protocols = append(protocols, p)
+			}
+		}
+	}
+	return protocols
+}
+
+func IsWebSocketUpgrade(r *http.Request) bool {
+	return headerContainsToken(r.Header, "Connection", "upgrade") &&
+		headerContainsToken(r.Header, "Upgrade", "websocket")
+}
+
+func computeAcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, value := range header[http.CanonicalHeaderKey(name)] {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
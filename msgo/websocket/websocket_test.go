@@ -0,0 +1,258 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func dial(t *testing.T, server *httptest.Server, header http.Header) (*Conn, *http.Response) {
+	t.Helper()
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	var k [16]byte
+	_, _ = rand.Read(k[:])
+	key := base64.StdEncoding.EncodeToString(k[:])
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, resp
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != computeAcceptKey(key) {
+		t.Fatalf("accept key = %q", got)
+	}
+	c := newConn(conn, br, false)
+	if strings.Contains(resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate") {
+		c.negotiated = true
+		c.compress = true
+	}
+	return c, resp
+}
+
+func echoServer(t *testing.T, upgrader *Upgrader) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			messageType, p, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(messageType, p); err != nil {
+				return
+			}
+		}
+	}))
+}
+
+func TestEcho(t *testing.T) {
+	server := echoServer(t, &Upgrader{})
+	defer server.Close()
+	conn, _ := dial(t, server, nil)
+	defer conn.Close()
+
+	large := bytes.Repeat([]byte("码神之路"), 20000)
+	cases := []struct {
+		messageType int
+		data        []byte
+	}{
+		{TextMessage, []byte("hello")},
+		{BinaryMessage, []byte{0, 1, 2, 3}},
+		{TextMessage, bytes.Repeat([]byte("a"), 300)},
+		{TextMessage, large},
+	}
+	for _, tc := range cases {
+		if err := conn.WriteMessage(tc.messageType, tc.data); err != nil {
+			t.Fatal(err)
+		}
+		messageType, p, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if messageType != tc.messageType || !bytes.Equal(p, tc.data) {
+			t.Fatalf("echo mismatch: type %d len %d", messageType, len(p))
+		}
+	}
+}
+
+func TestFragmentedMessage(t *testing.T) {
+	server := echoServer(t, &Upgrader{WriteFragmentSize: 7})
+	defer server.Close()
+	conn, _ := dial(t, server, nil)
+	defer conn.Close()
+	conn.SetWriteFragmentSize(3)
+
+	want := []byte("fragmented message across frames")
+	if err := conn.WriteMessage(TextMessage, want); err != nil {
+		t.Fatal(err)
+	}
+	// 分片之间插入的 ping 也要被正确处理
+	if err := conn.WriteControl(PingMessage, []byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	_, p, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(p, want) {
+		t.Fatalf("got %q", p)
+	}
+}
+
+func TestPingPong(t *testing.T) {
+	server := echoServer(t, &Upgrader{})
+	defer server.Close()
+	conn, _ := dial(t, server, nil)
+	defer conn.Close()
+
+	pong := make(chan string, 1)
+	conn.SetPongHandler(func(data string) error {
+		pong <- data
+		return nil
+	})
+	if err := conn.WriteControl(PingMessage, []byte("are you there")); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.WriteMessage(TextMessage, []byte("after ping")); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := conn.ReadMessage(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case data := <-pong:
+		if data != "are you there" {
+			t.Fatalf("pong payload = %q", data)
+		}
+	default:
+		t.Fatal("pong not received")
+	}
+}
+
+func TestCloseCode(t *testing.T) {
+	server := echoServer(t, &Upgrader{})
+	defer server.Close()
+	conn, _ := dial(t, server, nil)
+	defer conn.conn.Close()
+
+	if err := conn.WriteControl(CloseMessage, FormatCloseMessage(CloseGoingAway, "bye")); err != nil {
+		t.Fatal(err)
+	}
+	// 服务端会原样回应关闭码
+	_, _, err := conn.ReadMessage()
+	if !IsCloseError(err, CloseGoingAway) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	server := echoServer(t, &Upgrader{})
+	defer server.Close()
+	conn, _ := dial(t, server, nil)
+	defer conn.conn.Close()
+
+	if err := conn.writeFrame(true, false, TextMessage, []byte{0xff, 0xfe}); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err := conn.ReadMessage()
+	if !IsCloseError(err, CloseInvalidFramePayloadData) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestReservedControlOpcode(t *testing.T) {
+	server := echoServer(t, &Upgrader{})
+	defer server.Close()
+	conn, _ := dial(t, server, nil)
+	defer conn.conn.Close()
+
+	// 保留的控制帧不能当作关闭帧处理 需要以 1002 断开连接
+	if err := conn.writeFrame(true, false, 0xB, nil); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err := conn.ReadMessage()
+	if !IsCloseError(err, CloseProtocolError) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestReadLimit(t *testing.T) {
+	server := echoServer(t, &Upgrader{ReadLimit: 16})
+	defer server.Close()
+	conn, _ := dial(t, server, nil)
+	defer conn.conn.Close()
+
+	if err := conn.WriteMessage(BinaryMessage, make([]byte, 17)); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err := conn.ReadMessage()
+	if !IsCloseError(err, CloseMessageTooBig) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestCompression(t *testing.T) {
+	server := echoServer(t, &Upgrader{EnableCompression: true})
+	defer server.Close()
+	header := http.Header{"Sec-Websocket-Extensions": {"permessage-deflate; client_max_window_bits"}}
+	conn, resp := dial(t, server, header)
+	defer conn.Close()
+	if !conn.negotiated {
+		t.Fatalf("extension not negotiated: %q", resp.Header.Get("Sec-WebSocket-Extensions"))
+	}
+	want := bytes.Repeat([]byte("compress me "), 1000)
+	for i := 0; i < 3; i++ {
+		if err := conn.WriteMessage(TextMessage, want); err != nil {
+			t.Fatal(err)
+		}
+		_, p, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(p, want) {
+			t.Fatalf("got %d bytes", len(p))
+		}
+	}
+}
+
+func TestHandshakeRejected(t *testing.T) {
+	server := echoServer(t, &Upgrader{})
+	defer server.Close()
+
+	_, resp := dial(t, server, http.Header{"Origin": {"http://evil.example"}})
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	_, resp = dial(t, server, http.Header{"Sec-Websocket-Version": {"8"}})
+	if resp.StatusCode != http.StatusUpgradeRequired {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+}